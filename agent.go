@@ -0,0 +1,257 @@
+/*
+* ssh-agent status and adding connection keys with a lifetime
+ */
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// defaultAgentLifetime is how long keys added from sshman stay in the agent
+const defaultAgentLifetime = "1h"
+
+// agentTimeout bounds agent requests so a stale socket does not block the UI
+const agentTimeout = 2 * time.Second
+
+// Number of agent keys listed in the status area
+const agentStatusKeys = 3
+
+var (
+	agentText      *tview.TextView // agent status next to the help text
+	agentStatusSeq int             // number of the latest status request, used on the UI goroutine
+	agentKeyCache  *agentKeyList   // answer of the last agent query, nil to query again; used on the UI goroutine
+)
+
+// agentKeyList is the answer of an agent key listing
+type agentKeyList struct {
+	keys []*agent.Key
+	err  error
+}
+
+// agentKeys returns the keys loaded in the agent at SSH_AUTH_SOCK
+func agentKeys() ([]*agent.Key, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New(currentLang["agent_no_socket"])
+	}
+	conn, err := net.DialTimeout("unix", socket, agentTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(agentTimeout))
+	return agent.NewClient(conn).List()
+}
+
+// agentLifetime returns the ssh-add -t value, empty to keep keys until the agent exits
+func agentLifetime() string {
+	switch config.AgentLifetime {
+	case "":
+		return defaultAgentLifetime
+	case "0":
+		return ""
+	}
+	return config.AgentLifetime
+}
+
+// identityPublicKey reads the public key of a private key file, from the .pub file next to it
+// or from an unencrypted or OpenSSH-format key itself. encrypted reports whether the private
+// key needs a passphrase
+func identityPublicKey(path string) (public ssh.PublicKey, encrypted bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case err == nil:
+		return signer.PublicKey(), false, nil
+	case errors.As(err, &missing):
+		encrypted = true
+		if missing.PublicKey != nil {
+			return missing.PublicKey, true, nil
+		}
+	}
+
+	publicData, readErr := os.ReadFile(path + ".pub")
+	if readErr != nil {
+		return nil, encrypted, err
+	}
+	public, _, _, _, err = ssh.ParseAuthorizedKey(publicData)
+	return public, encrypted, err
+}
+
+// agentHasKey reports whether the public key is loaded in the agent
+func agentHasKey(keys []*agent.Key, public ssh.PublicKey) bool {
+	for _, key := range keys {
+		if bytes.Equal(key.Marshal(), public.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// agentStatusText describes the agent keys and whether the keys of the connection are loaded
+func agentStatusText(conn *SSHConnection, keys []*agent.Key, err error) string {
+	if err != nil {
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			return " " + currentLang["agent_no_socket"]
+		}
+		return " " + fmt.Sprintf(currentLang["agent_unavailable"], err)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, " "+currentLang["agent_keys"]+"\n", len(keys))
+	for i, key := range keys {
+		if i == agentStatusKeys {
+			fmt.Fprintf(&text, "   "+currentLang["agent_more"]+"\n", len(keys)-i)
+			break
+		}
+		comment := key.Comment
+		if comment == "" {
+			comment = ssh.FingerprintSHA256(key)
+		}
+		fmt.Fprintf(&text, "   %s %s\n", strings.TrimPrefix(key.Format, "ssh-"), tview.Escape(comment))
+	}
+
+	if conn == nil {
+		return text.String()
+	}
+	for _, path := range identityFiles(*conn) {
+		public, _, err := identityPublicKey(path)
+		if err != nil {
+			// Missing default keys are not worth a line
+			if conn.IdentityFile != "" {
+				fmt.Fprintf(&text, " [yellow]%s: %s[-]\n", tview.Escape(filepath.Base(path)), currentLang["agent_key_missing"])
+			}
+			continue
+		}
+		if agentHasKey(keys, public) {
+			fmt.Fprintf(&text, " [green]%s: %s[-]\n", tview.Escape(filepath.Base(path)), currentLang["agent_key_loaded"])
+		} else {
+			fmt.Fprintf(&text, " [yellow]%s: %s[-]\n", tview.Escape(filepath.Base(path)), currentLang["agent_key_not_loaded"])
+		}
+	}
+	return text.String()
+}
+
+// refreshAgentStatus updates the agent status area for the connection at the index
+// The keys of the last agent query are reused, the agent is only queried, in the background,
+// when none are cached; answers to earlier requests arriving late, after the cursor moved
+// on, are dropped
+func refreshAgentStatus(app *tview.Application, index int) {
+	var conn *SSHConnection
+	if index >= 0 && index < len(sshConnections) {
+		resolved := resolveConnection(sshConnections[index])
+		conn = &resolved
+	}
+	agentStatusSeq++
+	seq := agentStatusSeq
+	cached := agentKeyCache
+	go func() {
+		list := cached
+		if list == nil {
+			list = &agentKeyList{}
+			list.keys, list.err = agentKeys()
+		}
+		text := agentStatusText(conn, list.keys, list.err)
+		app.QueueUpdateDraw(func() {
+			if seq == agentStatusSeq {
+				agentKeyCache = list
+				agentText.SetText(text)
+			}
+		})
+	}()
+}
+
+// reloadAgentStatus queries the agent again and updates the status area, after keys may
+// have been added or on an explicit refresh
+func reloadAgentStatus(app *tview.Application, index int) {
+	agentKeyCache = nil
+	refreshAgentStatus(app, index)
+}
+
+// agentMissingKey returns the identity file of the connection when it is passphrase
+// protected and not loaded in a running agent, the case where adding it saves prompts
+func agentMissingKey(conn SSHConnection) (string, bool) {
+	if conn.IdentityFile == "" {
+		return "", false
+	}
+	keys, err := agentKeys()
+	if err != nil {
+		return "", false
+	}
+	path := expandHome(conn.IdentityFile)
+	public, encrypted, err := identityPublicKey(path)
+	if err != nil || !encrypted || agentHasKey(keys, public) {
+		return "", false
+	}
+	return path, true
+}
+
+// addToAgent runs ssh-add for the key on the terminal, with the configured lifetime
+// A stored credential answers the passphrase prompt through askpass
+func addToAgent(conn SSHConnection, path string) error {
+	args := []string{}
+	if lifetime := agentLifetime(); lifetime != "" {
+		args = append(args, "-t", lifetime)
+	}
+	cmd := exec.Command("ssh-add", append(args, path)...)
+	cmd.Env = credentialEnv(conn)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// offerAgentKey asks whether to add the key of the connection to the agent before connecting
+func offerAgentKey(app *tview.Application, list *tview.List, server, path string) {
+	lifetime := agentLifetime()
+	if lifetime == "" {
+		lifetime = currentLang["agent_no_lifetime"]
+	}
+
+	modal := tview.NewModal()
+	modal.SetBackgroundColor(tcell.ColorNavy)
+	modal.SetTextColor(tcell.ColorWhite)
+	modal.SetButtonBackgroundColor(tcell.ColorDarkRed)
+	modal.SetButtonTextColor(tcell.ColorWhite)
+	modal.
+		SetText(fmt.Sprintf(currentLang["dlg_agent_add"], filepath.Base(path), lifetime)).
+		AddButtons([]string{currentLang["btn_agent_add"], currentLang["btn_connect"], currentLang["btn_cancel"]}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			switch buttonLabel {
+			case currentLang["btn_agent_add"]:
+				app.Suspend(func() {
+					conn, _ := findConnection(server)
+					if err := addToAgent(conn, path); err != nil {
+						log.Printf(currentLang["msg_agent_add_error"], err)
+					}
+					sshConnect(server, false)
+				})
+			case currentLang["btn_connect"]:
+				app.Suspend(func() {
+					sshConnect(server, false)
+				})
+			}
+			refreshConnectionsList(app, list, list.GetCurrentItem())
+			app.SetRoot(centerWidget(app, createMainLayout(app, list)), true)
+			reloadAgentStatus(app, list.GetCurrentItem())
+		})
+	app.SetRoot(centerWidget(app, modal), true)
+}
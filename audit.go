@@ -0,0 +1,387 @@
+/*
+* Append-only audit log of connection activity, chained with SHA-256 hashes
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Audit actions
+const (
+	auditConnect    = "connect"
+	auditDisconnect = "disconnect"
+	auditAdd        = "add"
+	auditEdit       = "edit"
+	auditDelete     = "delete"
+	auditRestore    = "restore"
+	auditPurge      = "purge"
+)
+
+// Longest audit line read back; entries hold at most two connections
+const auditMaxLine = 1 << 20
+
+var auditFilePath = filepath.Join(configDir, "audit.jsonl")
+
+// AuditEntry is one line of the audit log
+// Seq is the line number, PrevHash the hash of the line before and Hash the SHA-256 of the
+// entry encoded with an empty hash, so editing, removing or reordering lines breaks the chain
+// With an encrypted config the connection settings stay out of the plaintext log: Fields
+// names the changed fields instead of Before and After, and Server holds the private name
+type AuditEntry struct {
+	Seq      int             `json:"seq"`
+	Time     time.Time       `json:"time"`
+	User     string          `json:"user"`
+	Action   string          `json:"action"`
+	Server   string          `json:"server"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	After    json.RawMessage `json:"after,omitempty"`
+	Fields   []string        `json:"fields,omitempty"`
+	ExitCode *int            `json:"exit_code,omitempty"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+// digest returns the hash of the entry
+func (entry AuditEntry) digest() (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// changedFields returns the names of the connection fields changed by the entry
+func (entry AuditEntry) changedFields() []string {
+	if len(entry.Fields) > 0 {
+		return entry.Fields
+	}
+	return diffFields(entry.Before, entry.After)
+}
+
+// diffFields returns the names of the fields that differ between two encoded connections
+func diffFields(beforeData, afterData json.RawMessage) []string {
+	var before, after map[string]json.RawMessage
+	_ = json.Unmarshal(beforeData, &before)
+	_ = json.Unmarshal(afterData, &after)
+
+	var fields []string
+	for name, value := range before {
+		if !bytes.Equal(value, after[name]) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// lastAuditLine returns the number of lines in the log and the hash of the last one,
+// empty when the last line is damaged so the next entry shows up as a broken link
+func lastAuditLine(reader io.Reader) (int, string, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, auditMaxLine)
+	lines := 0
+	var last []byte
+	for scanner.Scan() {
+		lines++
+		last = append(last[:0], scanner.Bytes()...)
+	}
+	var entry AuditEntry
+	if json.Unmarshal(last, &entry) != nil {
+		entry.Hash = ""
+	}
+	return lines, entry.Hash, scanner.Err()
+}
+
+// appendAudit chains the entry to the end of the audit log
+// The file is locked while appending, sessions in other sshman processes write to it too
+func appendAudit(entry AuditEntry) error {
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(auditFilePath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	lines, prevHash, err := lastAuditLine(file)
+	if err != nil {
+		return err
+	}
+	entry.Seq = lines + 1
+	entry.PrevHash = prevHash
+	if entry.Hash, err = entry.digest(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// recordAudit adds the entry to the audit log with the time and user
+// Failures are reported but never stop the action
+func recordAudit(entry AuditEntry) {
+	entry.Time = time.Now()
+	entry.User = localUser()
+	entry.Server = privateName(entry.Server)
+	if err := appendAudit(entry); err != nil {
+		log.Printf(currentLang["msg_audit_error"], err)
+	}
+}
+
+// auditChange records an added, edited or deleted connection; before is nil for an added
+// connection and after for a deleted one. Edits that change nothing are not recorded
+func auditChange(action string, before, after *SSHConnection) {
+	entry := AuditEntry{Action: action}
+	var err error
+	if before != nil {
+		entry.Server = before.Server
+		if entry.Before, err = json.Marshal(before); err != nil {
+			log.Printf(currentLang["msg_audit_error"], err)
+			return
+		}
+	}
+	if after != nil {
+		entry.Server = after.Server
+		if entry.After, err = json.Marshal(after); err != nil {
+			log.Printf(currentLang["msg_audit_error"], err)
+			return
+		}
+	}
+	if before != nil && after != nil && bytes.Equal(entry.Before, entry.After) {
+		return
+	}
+	if configEncrypted {
+		if before != nil && after != nil {
+			entry.Fields = diffFields(entry.Before, entry.After)
+		}
+		entry.Before, entry.After = nil, nil
+	}
+	recordAudit(entry)
+}
+
+// auditConnections records the connections added, edited and deleted between two
+// states of the list, as restored by undo and redo
+func auditConnections(before, after []SSHConnection) {
+	for i := range before {
+		found := false
+		for j := range after {
+			if after[j].Server == before[i].Server {
+				auditChange(auditEdit, &before[i], &after[j])
+				found = true
+				break
+			}
+		}
+		if !found {
+			auditChange(auditDelete, &before[i], nil)
+		}
+	}
+	for j := range after {
+		found := false
+		for i := range before {
+			if before[i].Server == after[j].Server {
+				found = true
+				break
+			}
+		}
+		if !found {
+			auditChange(auditAdd, nil, &after[j])
+		}
+	}
+}
+
+// verifyAudit checks the hash chain of the audit log and returns the number of entries,
+// the hash of the last one and a description of every broken link
+func verifyAudit() (int, string, []string, error) {
+	file, err := os.Open(auditFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, "", nil, nil
+		}
+		return 0, "", nil, err
+	}
+	defer file.Close()
+
+	var problems []string
+	lines := 0
+	prevHash := ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, auditMaxLine)
+	for scanner.Scan() {
+		lines++
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			problems = append(problems, fmt.Sprintf(currentLang["audit_bad_line"], lines, err))
+			prevHash = ""
+			continue
+		}
+		if entry.Seq != lines {
+			problems = append(problems, fmt.Sprintf(currentLang["audit_bad_seq"], lines, entry.Seq))
+		}
+		if entry.PrevHash != prevHash {
+			problems = append(problems, fmt.Sprintf(currentLang["audit_bad_link"], lines))
+		}
+		if hash, err := entry.digest(); err != nil || hash != entry.Hash {
+			problems = append(problems, fmt.Sprintf(currentLang["audit_bad_hash"], lines))
+		}
+		prevHash = entry.Hash
+	}
+	return lines, prevHash, problems, scanner.Err()
+}
+
+// auditFilter selects audit entries for the query command
+type auditFilter struct {
+	server string
+	action string
+	since  time.Time
+}
+
+// matches reports whether the entry passes the filter
+func (filter auditFilter) matches(entry AuditEntry) bool {
+	return (filter.server == "" || entry.Server == filter.server || entry.Server == keyedName(filter.server)) &&
+		(filter.action == "" || entry.Action == filter.action) &&
+		!entry.Time.Before(filter.since)
+}
+
+// parseSince reads a -since value, a duration back from now or a date
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", time.RFC3339} {
+		if since, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return since, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(currentLang["msg_audit_since"], value)
+}
+
+// printAudit writes the entries of the audit log passing the filter, as a table or as the
+// original JSON lines
+func printAudit(filter auditFilter, asJSON bool) error {
+	file, err := os.Open(auditFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if !asJSON {
+		fmt.Fprintln(writer, currentLang["cli_audit_header"])
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, auditMaxLine)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || !filter.matches(entry) {
+			continue
+		}
+		if asJSON {
+			fmt.Println(scanner.Text())
+			continue
+		}
+		code := ""
+		if entry.ExitCode != nil {
+			code = fmt.Sprint(*entry.ExitCode)
+		}
+		changes := ""
+		if entry.Action == auditEdit {
+			changes = strings.Join(entry.changedFields(), ", ")
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Format("2006-01-02 15:04:05"),
+			entry.User, entry.Action, serverOfPrivateName(entry.Server), code, changes)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// auditCommand lists the audit log, optionally filtered, or verifies its hash chain
+func auditCommand(args []string) int {
+	if len(args) == 1 && args[0] == "verify" {
+		count, lastHash, problems, err := verifyAudit()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		if len(problems) > 0 {
+			fmt.Fprintf(os.Stderr, currentLang["msg_audit_broken"]+"\n", len(problems), count)
+			return 1
+		}
+		fmt.Printf(currentLang["msg_audit_intact"]+"\n", count, lastHash)
+		return 0
+	}
+
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	server := flags.String("server", "", "")
+	action := flags.String("action", "", "")
+	since := flags.String("since", "", "")
+	asJSON := flags.Bool("json", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+
+	// Entries of an encrypted config hold private names; the table and -server need the
+	// key of the config to match them with servers, a table without it shows them as they are
+	if *server != "" || !*asJSON {
+		if err := unlockFromTerminal(); err != nil && *server != "" {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	filter := auditFilter{server: *server, action: *action}
+	var err error
+	if filter.since, err = parseSince(*since); err == nil {
+		err = printAudit(filter, *asJSON)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
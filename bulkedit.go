@@ -0,0 +1,332 @@
+/*
+* Bulk edit of the selected connections
+ */
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// bulkField is a connection field the bulk edit form can set on all selected connections
+// Fields with values are dropdowns, the others input fields
+type bulkField struct {
+	label  string
+	values []string
+	labels []string
+	get    func(conn SSHConnection) string
+	set    func(conn *SSHConnection, value string)
+}
+
+// display returns the value as shown in the preview
+func (field bulkField) display(value string) string {
+	for i, option := range field.values {
+		if option == value {
+			return field.labels[i]
+		}
+	}
+	if value == "" {
+		return currentLang["bulk_empty"]
+	}
+	return value
+}
+
+// yesNo returns the stored value of a boolean field
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// bulkFields returns the fields of the bulk edit form
+func bulkFields() []bulkField {
+	text := func(label string, get func(conn SSHConnection) string, set func(conn *SSHConnection, value string)) bulkField {
+		return bulkField{label: label, get: get, set: set}
+	}
+	clients, clientLabels := connectionClients()
+	transports, transportLabels := connectionTransports()
+	ttyValues, ttyLabels := ttyModes()
+	onOff := []string{"yes", "no"}
+	onOffLabels := []string{currentLang["bulk_on"], currentLang["bulk_off"]}
+
+	return []bulkField{
+		text("form_port",
+			func(conn SSHConnection) string { return conn.Port },
+			func(conn *SSHConnection, value string) { conn.Port = value }),
+		text("form_username",
+			func(conn SSHConnection) string { return conn.Username },
+			func(conn *SSHConnection, value string) { conn.Username = value }),
+		text("form_identity",
+			func(conn SSHConnection) string { return conn.IdentityFile },
+			func(conn *SSHConnection, value string) { conn.IdentityFile = value }),
+		text("form_jump_host",
+			func(conn SSHConnection) string { return conn.JumpHost },
+			func(conn *SSHConnection, value string) { conn.JumpHost = value }),
+		text("form_group",
+			func(conn SSHConnection) string { return conn.Group },
+			func(conn *SSHConnection, value string) { conn.Group = value }),
+		text("form_terminal",
+			func(conn SSHConnection) string { return conn.Terminal },
+			func(conn *SSHConnection, value string) { conn.Terminal = value }),
+		text("form_remote_command",
+			func(conn SSHConnection) string { return conn.RemoteCommand },
+			func(conn *SSHConnection, value string) { conn.RemoteCommand = value }),
+		{
+			label: "form_client", values: clients, labels: clientLabels,
+			get: func(conn SSHConnection) string {
+				if conn.Client == "" {
+					return clientExec
+				}
+				return conn.Client
+			},
+			set: func(conn *SSHConnection, value string) {
+				conn.Client = value
+				if value == clientExec {
+					conn.Client = ""
+				}
+			},
+		},
+		{
+			label: "form_transport", values: transports, labels: transportLabels,
+			get: func(conn SSHConnection) string {
+				if conn.Transport == "" {
+					return transportSSH
+				}
+				return conn.Transport
+			},
+			set: func(conn *SSHConnection, value string) {
+				conn.Transport = value
+				if value == transportSSH {
+					conn.Transport = ""
+				}
+			},
+		},
+		{
+			label: "form_request_tty", values: ttyValues, labels: ttyLabels,
+			get: func(conn SSHConnection) string { return conn.RequestTTY },
+			set: func(conn *SSHConnection, value string) { conn.RequestTTY = value },
+		},
+		{
+			label: "form_record", values: onOff, labels: onOffLabels,
+			get: func(conn SSHConnection) string { return yesNo(conn.Record) },
+			set: func(conn *SSHConnection, value string) { conn.Record = value == "yes" },
+		},
+		{
+			label: "form_reconnect", values: onOff, labels: onOffLabels,
+			get: func(conn SSHConnection) string { return yesNo(conn.Reconnect != nil) },
+			set: func(conn *SSHConnection, value string) {
+				// Keep the configured attempts and delay of enabled policies
+				if value == "no" {
+					conn.Reconnect = nil
+				} else if conn.Reconnect == nil {
+					conn.Reconnect = &ReconnectPolicy{}
+				}
+			},
+		},
+	}
+}
+
+// bulkChanges describes what applying the values changes on each connection, one line per field
+func bulkChanges(fields []bulkField, values map[int]string, connections []SSHConnection) []string {
+	var lines []string
+	for _, conn := range connections {
+		for i, field := range fields {
+			value, ok := values[i]
+			if !ok {
+				continue
+			}
+			updated := conn
+			field.set(&updated, value)
+			if old, changed := field.get(conn), field.get(updated); old != changed {
+				lines = append(lines, fmt.Sprintf(" %s  %s: %s → %s", tview.Escape(conn.Server),
+					currentLang[field.label], tview.Escape(field.display(old)), tview.Escape(field.display(changed))))
+			}
+		}
+	}
+	return lines
+}
+
+// applyBulkEdit sets the values on the connections as a single undoable operation
+func applyBulkEdit(fields []bulkField, values map[int]string, connections []SSHConnection) {
+	before := journalSnapshot()
+	var changed [][2]SSHConnection
+	for _, conn := range connections {
+		for index := range sshConnections {
+			if sshConnections[index].Server != conn.Server {
+				continue
+			}
+			old := sshConnections[index]
+			for i, value := range values {
+				fields[i].set(&sshConnections[index], value)
+			}
+			changed = append(changed, [2]SSHConnection{old, sshConnections[index]})
+		}
+	}
+	saveConnections()
+	for i := range changed {
+		auditChange(auditEdit, &changed[i][0], &changed[i][1])
+	}
+	recordOperation(fmt.Sprintf(currentLang["op_bulk_edit"], len(connections)), before)
+}
+
+// bulkEditForm edits the selected connections together; only fields that were changed
+// in the form are applied. Fields where the connections differ start empty
+func bulkEditForm(app *tview.Application, connectionsList *tview.List, connections []SSHConnection) {
+	if len(connections) == 0 {
+		return
+	}
+	backToMain := func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	}
+
+	fields := bulkFields()
+	values := make(map[int]string)
+	servers := make([]string, len(connections))
+	for i, conn := range connections {
+		servers[i] = conn.Server
+	}
+
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+	form := newStyledForm()
+	// The host list and a dozen fields do not fit with padding
+	form.SetItemPadding(0)
+	form.AddTextView(currentLang["form_hosts"], strings.Join(servers, ", "), 60, 2, true, false)
+
+	for i, field := range fields {
+		index := i
+		// The value shared by all connections, if any
+		common, same := field.get(connections[0]), true
+		for _, conn := range connections[1:] {
+			if field.get(conn) != common {
+				same = false
+			}
+		}
+
+		if field.values == nil {
+			initial := common
+			if !same {
+				initial = ""
+			}
+			input := tview.NewInputField().
+				SetLabel(currentLang[field.label]).
+				SetText(initial).
+				SetFieldWidth(40)
+			if !same {
+				setPlaceholder(input, currentLang["bulk_mixed_clear"])
+			}
+			input.SetChangedFunc(func(text string) {
+				// Emptied again, a field where the connections differ keeps their values
+				if !same && text == "" {
+					delete(values, index)
+					setPlaceholder(input, currentLang["bulk_mixed_clear"])
+					return
+				}
+				values[index] = strings.TrimSpace(text)
+			})
+			if !same {
+				// Clearing the differing values takes an explicit Ctrl+D
+				input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+					if event.Key() != tcell.KeyCtrlD {
+						return event
+					}
+					input.SetText("")
+					values[index] = ""
+					setPlaceholder(input, currentLang["bulk_cleared"])
+					return nil
+				})
+			}
+			form.AddFormItem(input)
+			continue
+		}
+
+		// The first option keeps the current values
+		shown := currentLang["bulk_mixed"]
+		if same {
+			shown = field.display(common)
+		}
+		keep := fmt.Sprintf(currentLang["bulk_keep"], shown)
+		form.AddDropDown(currentLang[field.label], append([]string{keep}, field.labels...), 0, func(option string, optionIndex int) {
+			if optionIndex == 0 {
+				delete(values, index)
+			} else {
+				values[index] = fields[index].values[optionIndex-1]
+			}
+		})
+	}
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(errorText, 1, 0, false)
+	showForm := func() {
+		app.SetRoot(centerForm(app, formFlex, form), true)
+		app.SetFocus(form)
+	}
+
+	form.
+		AddButton(currentLang["btn_preview"], func() {
+			changes := bulkChanges(fields, values, connections)
+			if len(changes) == 0 {
+				errorText.SetText(currentLang["msg_bulk_nothing"])
+				return
+			}
+			errorText.SetText("")
+			showBulkPreview(app, changes, func() {
+				applyBulkEdit(fields, values, connections)
+				refreshConnectionsList(app, connectionsList, connectionsList.GetCurrentItem())
+				checkHostsOnline(app, connectionsList, selectedConnections(connectionsList.GetCurrentItem()))
+				backToMain()
+			}, showForm)
+		}).
+		AddButton(currentLang["btn_cancel"], backToMain)
+
+	formFlex.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_bulk_edit"], len(connections))).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+	showForm()
+}
+
+// showBulkPreview lists the changes of a bulk edit with buttons to apply them or go back
+func showBulkPreview(app *tview.Application, changes []string, apply, back func()) {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText(strings.Join(changes, "\n"))
+	view.SetBackgroundColor(tcell.ColorNavy)
+	view.SetTextColor(tcell.ColorWhite)
+
+	buttons := newStyledForm().
+		AddButton(currentLang["btn_apply"], apply).
+		AddButton(currentLang["btn_back"], back)
+	buttons.SetCancelFunc(back)
+	// The buttons keep the focus, arrows scroll the changes
+	buttons.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			view.InputHandler()(event, nil)
+			return nil
+		}
+		return event
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(view, 0, 1, false).
+		AddItem(buttons, 3, 0, true)
+	layout.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_bulk_preview"], len(changes))).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerWidget(app, layout), true)
+	app.SetFocus(buttons)
+}
@@ -0,0 +1,175 @@
+/*
+* SSH user certificates next to identity files: validity, list warnings and renewal
+ */
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// certWarning is how long before expiry the connection list starts warning
+const certWarning = time.Hour
+
+// Certificate states
+const (
+	certNone = iota
+	certValid
+	certExpiring
+	certExpired
+	certNotYetValid
+)
+
+// cachedCert is a parsed certificate file with the modification time and size it was read at
+type cachedCert struct {
+	modified time.Time
+	size     int64
+	cert     *ssh.Certificate
+	err      error
+}
+
+// Certificates by path; the connection list checks them on every refresh
+var (
+	certCache      = make(map[string]cachedCert)
+	certCacheMutex sync.Mutex
+)
+
+// certificatePath returns the certificate file ssh uses with the key of the connection,
+// <identity>-cert.pub of the first key file that exists; empty without a key
+func certificatePath(conn SSHConnection) string {
+	for _, path := range identityFiles(conn) {
+		if _, err := os.Stat(path); err == nil {
+			return path + "-cert.pub"
+		}
+	}
+	return ""
+}
+
+// loadCertificate reads an OpenSSH certificate file, parsing it again only after it changed
+func loadCertificate(path string) (*ssh.Certificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	certCacheMutex.Lock()
+	defer certCacheMutex.Unlock()
+	if cached, ok := certCache[path]; ok && cached.modified.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.cert, cached.err
+	}
+	cert, err := parseCertificateFile(path)
+	certCache[path] = cachedCert{modified: info.ModTime(), size: info.Size(), cert: cert, err: err}
+	return cert, err
+}
+
+// parseCertificateFile reads and parses an OpenSSH certificate file
+func parseCertificateFile(path string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := public.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf(currentLang["msg_not_certificate"], path)
+	}
+	return cert, nil
+}
+
+// certState returns the state of the certificate at the time
+func certState(cert *ssh.Certificate, now time.Time) int {
+	unix := uint64(now.Unix())
+	switch {
+	case unix < cert.ValidAfter:
+		return certNotYetValid
+	case cert.ValidBefore == ssh.CertTimeInfinity:
+		return certValid
+	case unix >= cert.ValidBefore:
+		return certExpired
+	case time.Unix(int64(cert.ValidBefore), 0).Sub(now) < certWarning:
+		return certExpiring
+	}
+	return certValid
+}
+
+// connectionCertificate returns the certificate of the connection and its state,
+// certNone when there is none
+func connectionCertificate(conn SSHConnection) (*ssh.Certificate, int) {
+	path := certificatePath(conn)
+	if path == "" {
+		return nil, certNone
+	}
+	cert, err := loadCertificate(path)
+	if err != nil {
+		return nil, certNone
+	}
+	return cert, certState(cert, time.Now())
+}
+
+// formatCertTime formats a certificate validity bound
+func formatCertTime(value uint64) string {
+	if value == ssh.CertTimeInfinity {
+		return currentLang["cert_forever"]
+	}
+	return time.Unix(int64(value), 0).Format("2006-01-02 15:04")
+}
+
+// certWarningText returns the list marker and its color for a certificate that needs
+// attention, empty when it is valid or missing
+func certWarningText(conn SSHConnection) (string, string) {
+	cert, state := connectionCertificate(conn)
+	switch state {
+	case certExpired:
+		return currentLang["cert_expired"], "red"
+	case certNotYetValid:
+		return currentLang["cert_not_yet_valid"], "yellow"
+	case certExpiring:
+		left := time.Until(time.Unix(int64(cert.ValidBefore), 0)).Round(time.Minute)
+		return fmt.Sprintf(currentLang["cert_expiring"], strings.TrimSuffix(left.String(), "0s")), "yellow"
+	}
+	return "", ""
+}
+
+// certDetails describes the certificate of the connection for the details view
+func certDetails(conn SSHConnection) string {
+	cert, state := connectionCertificate(conn)
+	if state == certNone {
+		return ""
+	}
+	details := fmt.Sprintf(currentLang["cert_details"], strings.Join(cert.ValidPrincipals, " "),
+		formatCertTime(cert.ValidAfter), formatCertTime(cert.ValidBefore))
+	if warning, _ := certWarningText(conn); warning != "" {
+		details += " (" + warning + ")"
+	}
+	return details
+}
+
+// renewCertificate runs the renew command of the connection when its certificate is
+// missing, expired or about to expire
+func renewCertificate(conn SSHConnection) error {
+	if len(hookCommands(conn, hookRenewCertificate)) == 0 {
+		return nil
+	}
+	if _, state := connectionCertificate(conn); state == certValid {
+		return nil
+	}
+	return runHooks(conn, hookRenewCertificate, 0)
+}
+
+// certSigner returns the signer combined with the certificate next to the key file,
+// false when there is no certificate matching the key
+func certSigner(path string, signer ssh.Signer) (ssh.Signer, bool) {
+	cert, err := loadCertificate(path + "-cert.pub")
+	if err != nil {
+		return nil, false
+	}
+	withCert, err := ssh.NewCertSigner(cert, signer)
+	return withCert, err == nil
+}
@@ -0,0 +1,151 @@
+/*
+* Command line interface
+ */
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runCLI runs a command line subcommand and returns the process exit code
+func runCLI(args []string) int {
+	switch args[0] {
+	case "connect", "tunnels":
+		if err := unlockFromTerminal(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	switch args[0] {
+	case "audit":
+		return auditCommand(args[1:])
+	case "config":
+		return configCommand(args[1:])
+	case "connect":
+		return connectCommand(args[1:])
+	case "tunnels":
+		return tunnelsCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(currentLang["cli_usage"])
+		return 0
+	}
+	fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+	return 2
+}
+
+// connectCommand opens a session to a saved connection without the UI
+func connectCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+	if _, ok := findConnection(args[0]); !ok {
+		fmt.Fprintf(os.Stderr, currentLang["msg_conn_not_found"]+"\n", args[0])
+		return 1
+	}
+	sshConnect(args[0], false)
+	return 0
+}
+
+// configCommand encrypts or decrypts the configuration file, or prints a file sealed with it
+func configCommand(args []string) int {
+	arguments := 1
+	if len(args) > 0 && args[0] == "read" {
+		arguments = 2
+	}
+	if len(args) != arguments {
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "encrypt":
+		err = encryptConfigFile()
+	case "decrypt":
+		err = decryptConfigFile()
+	case "read":
+		if err = unlockFromTerminal(); err == nil {
+			err = printSealedFile(args[1])
+		}
+	default:
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// tunnelsCommand manages persistent tunnels: list, start, stop and the internal run
+func tunnelsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+	if args[0] != "list" && len(args) != 2 {
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = listTunnels()
+	case "start":
+		conn, ok := findConnection(args[1])
+		if !ok {
+			err = fmt.Errorf(currentLang["msg_conn_not_found"], args[1])
+			break
+		}
+		err = startPersistentTunnel(conn)
+	case "stop":
+		err = stopPersistentTunnel(args[1])
+	case "run":
+		// Reported through the sealed log of the supervisor
+		if err := superviseTunnel(args[1]); err != nil {
+			log.Print(err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, currentLang["cli_usage"])
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// listTunnels prints the persistent tunnels with their status
+func listTunnels() error {
+	states, err := loadTunnelStates()
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		fmt.Println(currentLang["msg_no_tunnels"])
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, currentLang["cli_tunnels_header"])
+	for _, state := range states {
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%d\t%s\t%s\n",
+			state.Server, state.PID, state.Status, time.Since(state.Started).Round(time.Second),
+			state.Attempts, strings.Join(state.Forwards, ", "), state.LastError)
+	}
+	return writer.Flush()
+}
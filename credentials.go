@@ -0,0 +1,282 @@
+/*
+* Passwords and passphrases in the system keyring or pass, answered through SSH_ASKPASS
+ */
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Credential stores
+const (
+	credentialKeyring = "keyring" // Secret Service through secret-tool
+	credentialPass    = "pass"    // the standard unix password manager
+)
+
+// Environment passed to sshman when ssh runs it as SSH_ASKPASS
+const (
+	askpassServerEnv   = "SSHMAN_ASKPASS_SERVER"
+	askpassStoreEnv    = "SSHMAN_ASKPASS_STORE"
+	askpassUserEnv     = "SSHMAN_ASKPASS_USER"
+	askpassIdentityEnv = "SSHMAN_ASKPASS_IDENTITY"
+	askpassBatchEnv    = "SSHMAN_ASKPASS_BATCH" // fail other prompts instead of asking the terminal
+)
+
+// credentialStores returns the store values and their display labels in dropdown order
+func credentialStores() ([]string, []string) {
+	return []string{"", credentialKeyring, credentialPass},
+		[]string{currentLang["credential_none"], currentLang["credential_keyring"], "pass"}
+}
+
+// passEntry returns the pass entry name of the server
+func passEntry(server string) string {
+	return "sshman/" + server
+}
+
+// storeCredential saves the secret of the server in the store
+func storeCredential(store, server, secret string) error {
+	var cmd *exec.Cmd
+	switch store {
+	case credentialKeyring:
+		cmd = exec.Command("secret-tool", "store", "--label=sshman "+server, "service", "sshman", "server", server)
+	case credentialPass:
+		cmd = exec.Command("pass", "insert", "--multiline", "--force", passEntry(server))
+	default:
+		return fmt.Errorf(currentLang["msg_credential_store"], store)
+	}
+	// The secret goes through stdin so it never appears in the process list
+	cmd.Stdin = strings.NewReader(secret)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v %s", cmd.Args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// lookupCredential returns the secret of the server from the store
+func lookupCredential(store, server string) (string, error) {
+	var cmd *exec.Cmd
+	switch store {
+	case credentialKeyring:
+		cmd = exec.Command("secret-tool", "lookup", "service", "sshman", "server", server)
+	case credentialPass:
+		cmd = exec.Command("pass", "show", passEntry(server))
+	default:
+		return "", fmt.Errorf(currentLang["msg_credential_store"], store)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	// pass keeps the password on the first line, secret-tool prints it without a newline
+	secret, _, _ := strings.Cut(string(output), "\n")
+	if secret == "" {
+		return "", errors.New(currentLang["msg_credential_missing"])
+	}
+	return secret, nil
+}
+
+// clearCredential removes the secret of the server from the store
+func clearCredential(store, server string) error {
+	var cmd *exec.Cmd
+	switch store {
+	case credentialKeyring:
+		cmd = exec.Command("secret-tool", "clear", "service", "sshman", "server", server)
+	case credentialPass:
+		cmd = exec.Command("pass", "rm", "--force", passEntry(server))
+	default:
+		return nil
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v %s", cmd.Args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// applyCredential updates the stored secret after a connection form is saved
+// A new secret is stored; without one the existing secret is copied to a renamed server or
+// a changed store. The old secret stays while undo can bring the old settings back, see
+// dropUnreferencedCredentials
+func applyCredential(old, updated SSHConnection, secret string) error {
+	moved := old.Credential != updated.Credential || old.Server != updated.Server
+	if updated.Credential == "" {
+		return nil
+	}
+
+	if secret == "" {
+		if old.Credential == "" {
+			return errors.New(currentLang["msg_enter_secret"])
+		}
+		if !moved {
+			return nil
+		}
+		var err error
+		if secret, err = lookupCredential(old.Credential, old.Server); err != nil {
+			return err
+		}
+	}
+	return storeCredential(updated.Credential, updated.Server, secret)
+}
+
+// credentialKey identifies a stored secret: the store and the server it is saved under
+type credentialKey struct {
+	store  string
+	server string
+}
+
+// addCredentialKeys adds the secrets the connections refer to
+func addCredentialKeys(keys map[credentialKey]bool, connections []SSHConnection) {
+	for _, conn := range connections {
+		if conn.Credential != "" {
+			keys[credentialKey{conn.Credential, conn.Server}] = true
+		}
+	}
+}
+
+// dropUnreferencedCredentials clears the secrets of the keys that neither the connections,
+// the trash nor the journal refer to any more
+// Callers collect the keys with referencedCredentials before changing them
+func dropUnreferencedCredentials(keys map[credentialKey]bool) {
+	referenced := referencedCredentials()
+	for key := range keys {
+		if referenced[key] {
+			continue
+		}
+		if err := clearCredential(key.store, key.server); err != nil {
+			log.Printf(currentLang["msg_credential_error"]+"\n", err)
+		}
+	}
+}
+
+// credentialEnv returns the environment making ssh ask sshman for the stored secret,
+// nil when the connection has no credential
+// SSH_ASKPASS_REQUIRE needs OpenSSH 8.4, older versions use askpass without a terminal only
+func credentialEnv(conn SSHConnection) []string {
+	if conn.Credential == "" {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	// The identity file as passed to ssh, which names it in the passphrase prompt
+	identity := ""
+	if conn.IdentityFile != "" {
+		identity = expandHome(conn.IdentityFile)
+	}
+	return append(os.Environ(),
+		"SSH_ASKPASS="+executable,
+		"SSH_ASKPASS_REQUIRE=force",
+		askpassServerEnv+"="+conn.Server,
+		askpassStoreEnv+"="+conn.Credential,
+		askpassUserEnv+"="+connectionUser(conn),
+		askpassIdentityEnv+"="+identity,
+	)
+}
+
+// batchCredentialEnv returns the credential environment for ssh running without a terminal,
+// like BatchMode: prompts other than for the stored secret fail
+func batchCredentialEnv(conn SSHConnection) []string {
+	env := credentialEnv(conn)
+	if env == nil {
+		return nil
+	}
+	return append(env, askpassBatchEnv+"=1")
+}
+
+// askpassOwnPrompt reports whether the prompt asks for the secret of the connection itself:
+// the password of user@server or the passphrase of its identity file
+// Jump hosts inherit the environment of ssh, their prompts must not get the secret
+func askpassOwnPrompt(prompt string) bool {
+	lower := strings.ToLower(prompt)
+	server := os.Getenv(askpassServerEnv)
+	if at := strings.LastIndex(server, "@"); at >= 0 {
+		server = server[at+1:]
+	}
+	if strings.Contains(lower, "password") && server != "" &&
+		strings.Contains(prompt, os.Getenv(askpassUserEnv)+"@"+server) {
+		return true
+	}
+	return strings.Contains(lower, "passphrase") && askpassIdentityPrompt(prompt, os.Getenv(askpassIdentityEnv))
+}
+
+// Longest identity path ssh puts in its passphrase prompt
+const sshPromptPathMax = 100
+
+// askpassIdentityPrompt reports whether the passphrase prompt names the identity file:
+// ssh asks "Enter passphrase for key '<path>': " with the path cut at 100 bytes and ssh-add
+// "Enter passphrase for <path>: ". The "Bad passphrase, try again" prompt of ssh-add goes
+// to the terminal, it would repeat a wrong stored passphrase forever
+func askpassIdentityPrompt(prompt, identity string) bool {
+	if identity == "" || strings.HasPrefix(prompt, "Bad passphrase") {
+		return false
+	}
+	quoted := identity
+	if len(quoted) > sshPromptPathMax {
+		quoted = quoted[:sshPromptPathMax]
+	}
+	return strings.Contains(prompt, "'"+quoted+"'") ||
+		strings.Contains(prompt, "for "+identity+":") ||
+		strings.Contains(prompt, "for "+identity+" (")
+}
+
+// askpassCommand answers an ssh prompt when sshman runs as SSH_ASKPASS
+// Password and passphrase prompts of the connection get the stored secret, other prompts
+// such as host key confirmations or jump host passwords are asked on the terminal
+func askpassCommand(args []string) int {
+	prompt := strings.Join(args, " ")
+	lower := strings.ToLower(prompt)
+	isSecret := strings.Contains(lower, "password") || strings.Contains(lower, "passphrase")
+	if askpassOwnPrompt(prompt) {
+		secret, err := lookupCredential(os.Getenv(askpassStoreEnv), os.Getenv(askpassServerEnv))
+		if err == nil {
+			fmt.Println(secret)
+			return 0
+		}
+	}
+	if os.Getenv(askpassBatchEnv) != "" {
+		return 1
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return 1
+	}
+	defer tty.Close()
+	fmt.Fprint(tty, prompt)
+	var answer string
+	if isSecret {
+		var secret []byte
+		secret, err = term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		answer = string(secret)
+	} else {
+		answer, err = bufio.NewReader(tty).ReadString('\n')
+		answer = strings.TrimRight(answer, "\r\n")
+	}
+	if err != nil {
+		return 1
+	}
+	fmt.Println(answer)
+	return 0
+}
+
+// connectionSecret returns the stored secret of the connection for the built-in client,
+// empty when there is none or it cannot be read
+func connectionSecret(conn SSHConnection) string {
+	if conn.Credential == "" {
+		return ""
+	}
+	secret, err := lookupCredential(conn.Credential, conn.Server)
+	if err != nil {
+		return ""
+	}
+	return secret
+}
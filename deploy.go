@@ -0,0 +1,208 @@
+/*
+* Public key deployment to remote authorized_keys, like ssh-copy-id
+ */
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+)
+
+// deployScript returns the remote shell script appending the key line to authorized_keys
+// unless the key is already there; comments are not compared
+func deployScript(key, keyLine string) string {
+	return strings.Join([]string{
+		"umask 077",
+		"mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys || exit 1",
+		"grep -qF " + shellQuote(key) + " ~/.ssh/authorized_keys && exit 0",
+		// Do not glue the key to a last line without a newline
+		`if [ -s ~/.ssh/authorized_keys ] && [ -n "$(tail -c 1 ~/.ssh/authorized_keys)" ]; then echo >> ~/.ssh/authorized_keys; fi`,
+		"printf '%s\\n' " + shellQuote(keyLine) + " >> ~/.ssh/authorized_keys",
+	}, "\n")
+}
+
+// deployKey appends the public key to authorized_keys on the host of the connection
+// Authentication is interactive, since the host usually does not accept the key yet
+func deployKey(conn SSHConnection, key sshKey) error {
+	data, err := os.ReadFile(key.publicPath)
+	if err != nil {
+		return err
+	}
+	public, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return err
+	}
+	keyText := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(public)))
+	keyLine := keyText
+	if comment != "" {
+		keyLine += " " + comment
+	}
+	// The login shell of the user may not be a POSIX shell
+	command := "sh -c " + shellQuote(deployScript(keyText, keyLine))
+
+	if conn.Client == clientNative {
+		client, err := dialNative(conn, true)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		session, err := client.NewSession()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		session.Stdout = os.Stdout
+		session.Stderr = os.Stderr
+		return session.Run(command)
+	}
+
+	args := append(sshArgs(conn), "--", command)
+	cmd := exec.Command("ssh", args...)
+	cmd.Env = credentialEnv(conn)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// deployKeys deploys the key to each connection on the terminal and returns the servers
+// that succeeded
+func deployKeys(connections []SSHConnection, key sshKey) []string {
+	var deployed []string
+	for _, conn := range connections {
+		fmt.Printf(currentLang["msg_deploying"], filepath.Base(key.publicPath), conn.Server)
+		started := time.Now()
+		err := deployKey(conn, key)
+		if err != nil {
+			log.Printf(currentLang["msg_deploy_error"], conn.Server, err)
+		} else {
+			deployed = append(deployed, conn.Server)
+		}
+		recordHistory(conn.Server, historyDeploy, key.publicPath, started, err)
+	}
+	fmt.Printf(currentLang["msg_deploy_done"], len(deployed), len(connections))
+
+	fmt.Print(currentLang["prompt_continue"])
+	_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	return deployed
+}
+
+// homeRelative returns the path with the home directory written as ~
+func homeRelative(path string) string {
+	home := os.Getenv("HOME")
+	if relative, err := filepath.Rel(home, path); err == nil && home != "" && !strings.HasPrefix(relative, "..") {
+		return filepath.Join("~", relative)
+	}
+	return path
+}
+
+// deployKeyForm asks for the public key to deploy to the connections
+func deployKeyForm(app *tview.Application, connectionsList *tview.List, connections []SSHConnection) {
+	if len(connections) == 0 {
+		return
+	}
+	backToMain := func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	}
+
+	allKeys, err := loadKeys()
+	if err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_key_error"], err))
+		return
+	}
+	// The identity file can only point to keys with a private half
+	var keys []sshKey
+	var labels []string
+	keyIndex := -1
+	for _, key := range allKeys {
+		if !key.hasPrivate {
+			continue
+		}
+		for _, conn := range connections {
+			if conn.IdentityFile != "" && identityPath(conn.IdentityFile) == key.path {
+				keyIndex = len(keys)
+			}
+		}
+		keys = append(keys, key)
+		labels = append(labels, fmt.Sprintf("%s (%s)", filepath.Base(key.publicPath), strings.TrimPrefix(key.keyType, "ssh-")))
+	}
+	// Without a key in use, offer the first strong one
+	for i := 0; keyIndex < 0 && i < len(keys); i++ {
+		if keys[i].weakness() == "" {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		keyIndex = 0
+	}
+	if len(keys) == 0 {
+		showError(app, connectionsList, currentLang["msg_no_keys"])
+		return
+	}
+
+	servers := make([]string, len(connections))
+	for i, conn := range connections {
+		servers[i] = conn.Server
+	}
+
+	form := newStyledForm()
+	form.
+		AddTextView(currentLang["form_hosts"], strings.Join(servers, ", "), 60, 2, true, false).
+		AddDropDown(currentLang["form_public_key"], labels, keyIndex, nil).
+		AddCheckbox(currentLang["form_set_identity"], true, nil).
+		AddButton(currentLang["btn_deploy"], func() {
+			index, _ := form.GetFormItem(1).(*tview.DropDown).GetCurrentOption()
+			setIdentity := form.GetFormItem(2).(*tview.Checkbox).IsChecked()
+			key := keys[index]
+
+			var deployed []string
+			app.Suspend(func() {
+				deployed = deployKeys(connections, key)
+			})
+			if setIdentity && len(deployed) > 0 {
+				beforeAll := journalSnapshot()
+				var before, after []SSHConnection
+				for _, server := range deployed {
+					for i := range sshConnections {
+						if sshConnections[i].Server == server {
+							before = append(before, sshConnections[i])
+							sshConnections[i].IdentityFile = homeRelative(key.path)
+							after = append(after, sshConnections[i])
+						}
+					}
+				}
+				saveConnections()
+				for i := range before {
+					auditChange(auditEdit, &before[i], &after[i])
+				}
+				recordOperation(fmt.Sprintf(currentLang["op_set_identity"], len(after)), beforeAll)
+				refreshConnectionsList(app, connectionsList, connectionsList.GetCurrentItem())
+			}
+			backToMain()
+		}).
+		AddButton(currentLang["btn_cancel"], backToMain)
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true)
+
+	formFlex.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_deploy_key"], len(connections))).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerForm(app, formFlex, form), true)
+	app.SetFocus(form)
+}
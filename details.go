@@ -0,0 +1,84 @@
+/*
+* Connection detail view
+ */
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// connectionDetails returns the label and value of each configured setting of the connection
+func connectionDetails(conn SSHConnection) [][2]string {
+	var details [][2]string
+	add := func(label, value string) {
+		if value != "" {
+			details = append(details, [2]string{currentLang[label], value})
+		}
+	}
+
+	clients, clientLabels := connectionClients()
+	client := clientLabels[0]
+	for i := range clients {
+		if clients[i] == conn.Client {
+			client = clientLabels[i]
+		}
+	}
+
+	add("form_server", conn.Server)
+	add("form_port", conn.Port)
+	add("form_comment", conn.Comment)
+	add("form_username", conn.Username)
+	add("form_identity", conn.IdentityFile)
+	add("details_certificate", certDetails(conn))
+	add("form_jump_host", conn.JumpHost)
+	add("form_client", client)
+	add("form_transport", conn.Transport)
+	add("form_transport_options", conn.TransportOptions)
+	add("form_group", conn.Group)
+	if recordingEnabled(conn) {
+		add("form_record", currentLang["details_yes"])
+	}
+	add("form_terminal", terminalTemplate(conn))
+	add("form_remote_command", conn.RemoteCommand)
+	add("form_request_tty", requestTTY(conn))
+	add("form_set_env", formatEnv(conn.SetEnv))
+	add("form_send_env", strings.Join(conn.SendEnv, " "))
+	add("details_forwards", strings.Join(forwardStrings(conn.Forwards), ", "))
+	add("details_pre_hooks", strings.Join(hookCommands(conn, hookPreConnect), "; "))
+	add("details_post_hooks", strings.Join(hookCommands(conn, hookPostConnect), "; "))
+	add("details_renew_certificate", strings.Join(hookCommands(conn, hookRenewCertificate), "; "))
+	if conn.Client != clientNative || (conn.Transport != "" && conn.Transport != transportSSH) {
+		// The command of a plain connect, forwards are only added by "Connect with forwards"
+		plain := conn
+		plain.Forwards = nil
+		add("details_command", shellCommand(transportCommand(plain)))
+	}
+	return details
+}
+
+// showConnectionDetails displays all settings of the connection
+func showConnectionDetails(app *tview.Application, connectionsList *tview.List, index int) {
+	if index < 0 || index >= len(sshConnections) {
+		return
+	}
+	conn := resolveConnection(sshConnections[index])
+
+	var text strings.Builder
+	for _, detail := range connectionDetails(conn) {
+		fmt.Fprintf(&text, " [yellow]%-22s[-] %s\n", detail[0]+":", tview.Escape(detail[1]))
+	}
+
+	view := tview.NewTextView().SetDynamicColors(true).SetText(text.String())
+	view.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_details"], conn.Server)).
+		SetTitleAlign(tview.AlignLeft)
+	view.SetBackgroundColor(tcell.ColorNavy)
+	view.SetDoneFunc(func(key tcell.Key) {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	})
+	app.SetRoot(centerWidget(app, view), true)
+}
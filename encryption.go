@@ -0,0 +1,394 @@
+/*
+* Encrypted configuration file: scrypt key derivation and AES-256-GCM
+ */
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Encrypted file format
+const (
+	encryptedFormat = "sshman-encrypted-v1"
+	scryptN         = 1 << 15
+	scryptR         = 8
+	scryptP         = 1
+	saltSize        = 16
+)
+
+// Environment giving command line subcommands the config passphrase: the passphrase
+// itself, set by the user, or the inherited descriptor it can be read from, set by sshman
+// for the background processes it starts
+const (
+	configPassphraseEnv   = "SSHMAN_CONFIG_PASSPHRASE"
+	configPassphraseFDEnv = "SSHMAN_CONFIG_PASSPHRASE_FD"
+)
+
+// defaultLockTimeout is the inactivity after which the UI locks an encrypted config
+const defaultLockTimeout = 15 * time.Minute
+
+// encryptedConfig is the on-disk form of an encrypted configuration file
+type encryptedConfig struct {
+	Format string `json:"format"`
+	KDF    string `json:"kdf"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	Salt   []byte `json:"salt"`
+	Nonce  []byte `json:"nonce"`
+	Data   []byte `json:"data"`
+}
+
+var (
+	configEncrypted  bool      // the file on disk is encrypted
+	configLocked     bool      // the file is encrypted and was not decrypted in this session
+	configPassphrase string    // passphrase of the unlocked file
+	lastActivity     time.Time // last key press in the UI
+)
+
+// parseEncryptedConfig reports whether the data is an encrypted configuration file
+func parseEncryptedConfig(data []byte) (encryptedConfig, bool) {
+	var envelope encryptedConfig
+	if json.Unmarshal(data, &envelope) != nil || envelope.Format != encryptedFormat {
+		return envelope, false
+	}
+	return envelope, true
+}
+
+// configCipher derives the key from the passphrase and returns the AEAD
+func configCipher(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptConfig encrypts the configuration with a fresh salt and nonce
+func encryptConfig(plain []byte, passphrase string) ([]byte, error) {
+	envelope := encryptedConfig{
+		Format: encryptedFormat,
+		KDF:    "scrypt",
+		N:      scryptN,
+		R:      scryptR,
+		P:      scryptP,
+		Salt:   make([]byte, saltSize),
+	}
+	if _, err := rand.Read(envelope.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := configCipher(passphrase, envelope.Salt, envelope.N, envelope.R, envelope.P)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return nil, err
+	}
+	envelope.Data = aead.Seal(nil, envelope.Nonce, plain, []byte(encryptedFormat))
+
+	data, err := json.MarshalIndent(envelope, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// decrypt returns the plaintext configuration, failing on a wrong passphrase or a damaged file
+func (envelope encryptedConfig) decrypt(passphrase string) ([]byte, error) {
+	if envelope.KDF != "scrypt" {
+		return nil, fmt.Errorf(currentLang["msg_config_kdf"], envelope.KDF)
+	}
+	aead, err := configCipher(passphrase, envelope.Salt, envelope.N, envelope.R, envelope.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, errors.New(currentLang["msg_wrong_passphrase"])
+	}
+	plain, err := aead.Open(nil, envelope.Nonce, envelope.Data, []byte(encryptedFormat))
+	if err != nil {
+		return nil, errors.New(currentLang["msg_wrong_passphrase"])
+	}
+	return plain, nil
+}
+
+// readConfigFile returns the configuration file contents, decrypted with the passphrase
+// of the session when the file is encrypted
+func readConfigFile() ([]byte, error) {
+	data, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	envelope, encrypted := parseEncryptedConfig(data)
+	configEncrypted = encrypted
+	if !encrypted {
+		configLocked = false
+		return data, nil
+	}
+
+	configLocked = true
+	if configPassphrase == "" {
+		return nil, errors.New(currentLang["msg_config_locked"])
+	}
+	plain, err := envelope.decrypt(configPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	configLocked = false
+	return plain, nil
+}
+
+// sealConfigFile encrypts the configuration for writing when the file is encrypted
+func sealConfigFile(data []byte) ([]byte, error) {
+	if !configEncrypted {
+		return data, nil
+	}
+	return encryptConfig(data, configPassphrase)
+}
+
+// unlockConfig loads the encrypted configuration with the passphrase
+func unlockConfig(passphrase string) error {
+	configPassphrase = passphrase
+	loadConnections()
+	if configLocked {
+		configPassphrase = ""
+		return errors.New(currentLang["msg_wrong_passphrase"])
+	}
+	ensureLogKey()
+	lastActivity = time.Now()
+	return nil
+}
+
+// lockConfig forgets the passphrase and the decrypted connections
+func lockConfig() {
+	closeJournal()
+	config = Config{}
+	sshConnections = nil
+	selected = make(map[string]bool)
+	configPassphrase = ""
+	configLocked = true
+}
+
+// lockTimeout returns the inactivity after which the UI locks, zero for never
+func lockTimeout() time.Duration {
+	switch {
+	case config.LockTimeout < 0:
+		return 0
+	case config.LockTimeout > 0:
+		return time.Duration(config.LockTimeout) * time.Minute
+	}
+	return defaultLockTimeout
+}
+
+// startWithPassphrase starts a background sshman process, handing it the passphrase of an
+// unlocked config through an inherited pipe it reads once; the environment of the process,
+// readable by other processes of the user, only names the descriptor
+func startWithPassphrase(cmd *exec.Cmd) error {
+	if !configEncrypted || configPassphrase == "" {
+		return cmd.Start()
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	// The passphrase fits in the pipe buffer, so it is written before the process starts
+	_, err = writer.WriteString(configPassphrase)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fd, err := inheritFile(cmd, reader)
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(os.Environ(), configPassphraseFDEnv+"="+strconv.Itoa(fd))
+	return cmd.Start()
+}
+
+// inheritedPassphrase reads the passphrase from the descriptor named in the environment
+func inheritedPassphrase(fd string) (string, error) {
+	number, err := strconv.Atoi(fd)
+	if err != nil {
+		return "", err
+	}
+	file := os.NewFile(uintptr(number), "passphrase")
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	return string(data), err
+}
+
+// unlockFromTerminal unlocks an encrypted config for command line use, with the passphrase
+// from the environment or asked on the terminal
+func unlockFromTerminal() error {
+	if !configLocked {
+		return nil
+	}
+	if fd := os.Getenv(configPassphraseFDEnv); fd != "" {
+		os.Unsetenv(configPassphraseFDEnv)
+		passphrase, err := inheritedPassphrase(fd)
+		if err != nil {
+			return err
+		}
+		return unlockConfig(passphrase)
+	}
+	if passphrase := os.Getenv(configPassphraseEnv); passphrase != "" {
+		// Sessions started from here must not inherit the passphrase
+		os.Unsetenv(configPassphraseEnv)
+		return unlockConfig(passphrase)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New(currentLang["msg_config_locked"])
+	}
+	passphrase, err := readSecret(currentLang["prompt_config_passphrase"])
+	if err != nil {
+		return err
+	}
+	return unlockConfig(passphrase)
+}
+
+// encryptConfigFile encrypts the plaintext configuration file with a new passphrase
+func encryptConfigFile() error {
+	if configEncrypted {
+		return errors.New(currentLang["msg_config_encrypted"])
+	}
+	plain, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(plain) {
+		return fmt.Errorf(currentLang["msg_config_invalid"], configFilePath)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New(currentLang["msg_passphrase_terminal"])
+	}
+
+	passphrase, err := readSecret(currentLang["prompt_new_passphrase"])
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New(currentLang["msg_empty_passphrase"])
+	}
+	confirm, err := readSecret(currentLang["prompt_confirm_passphrase"])
+	if err != nil {
+		return err
+	}
+	if confirm != passphrase {
+		return errors.New(currentLang["msg_passphrase_mismatch"])
+	}
+
+	data, err := encryptConfig(plain, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(configFilePath, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(configFilePath, 0600)
+}
+
+// decryptConfigFile writes the configuration file back in plaintext
+func decryptConfigFile() error {
+	if !configEncrypted {
+		return errors.New(currentLang["msg_config_not_encrypted"])
+	}
+	if err := unlockFromTerminal(); err != nil {
+		return err
+	}
+	plain, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configFilePath, plain, 0600)
+}
+
+// showUnlock asks for the passphrase of the encrypted configuration before the main window
+func showUnlock(app *tview.Application, connectionsList *tview.List) {
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+
+	form := newStyledForm()
+	form.AddPasswordField(currentLang["form_config_passphrase"], "", 40, '*', nil)
+	unlock := func() {
+		field := form.GetFormItem(0).(*tview.InputField)
+		if err := unlockConfig(field.GetText()); err != nil {
+			field.SetText("")
+			errorText.SetText(err.Error())
+			form.SetFocus(0)
+			app.SetFocus(form)
+			return
+		}
+
+		// The language and connections come from the decrypted config
+		connectionsList.SetTitle(currentLang["connections_title"])
+		menuList.SetTitle(currentLang["menu_title"])
+		helpText.SetText(currentLang["help_text"])
+		populateMenu(app, connectionsList)
+		loadJournal()
+		purgeExpiredTrash()
+		refreshConnectionsList(app, connectionsList, 0)
+		checkHostsOnline(app, connectionsList, sshConnections)
+		refreshAgentStatus(app, 0)
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+		app.SetFocus(connectionsList)
+	}
+	form.AddButton(currentLang["btn_unlock"], unlock).
+		AddButton(currentLang["menu_exit"], app.Stop)
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(errorText, 1, 0, false)
+
+	formFlex.SetBorder(true).
+		SetTitle(currentLang["title_unlock"]).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerBox(formFlex, formWidth, 8), true)
+	app.SetFocus(form)
+}
+
+// watchConfigLock locks the UI after the configured inactivity
+// The check runs on the UI goroutine, so a session that was just closed has already
+// counted as activity
+func watchConfigLock(app *tview.Application, connectionsList *tview.List) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		app.QueueUpdateDraw(func() {
+			timeout := lockTimeout()
+			if configLocked || timeout == 0 || time.Since(lastActivity) < timeout {
+				return
+			}
+			lockConfig()
+			refreshConnectionsList(app, connectionsList, 0)
+			showUnlock(app, connectionsList)
+		})
+	}
+}
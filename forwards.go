@@ -0,0 +1,368 @@
+/*
+* Port forwarding profiles
+ */
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+)
+
+// Port forward types
+const (
+	forwardLocal   = "local"
+	forwardRemote  = "remote"
+	forwardDynamic = "dynamic"
+)
+
+// PortForward is a named forwarding rule of a connection
+type PortForward struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`             // local (-L), remote (-R) or dynamic (-D)
+	Listen string `json:"listen"`           // [bind_address:]port
+	Target string `json:"target,omitempty"` // host:port, empty for dynamic forwards
+}
+
+// forwardTypes returns the forward type values and their display labels in dropdown order
+func forwardTypes() ([]string, []string) {
+	return []string{forwardLocal, forwardRemote, forwardDynamic},
+		[]string{currentLang["forward_local"], currentLang["forward_remote"], currentLang["forward_dynamic"]}
+}
+
+// sshFlag returns the ssh command line flag and value for the forward, e.g. -L 5432:localhost:5432
+func (f PortForward) sshFlag() (string, string) {
+	switch f.Type {
+	case forwardRemote:
+		return "-R", f.Listen + ":" + f.Target
+	case forwardDynamic:
+		return "-D", f.Listen
+	default:
+		return "-L", f.Listen + ":" + f.Target
+	}
+}
+
+// String returns the forward in ssh command line form
+func (f PortForward) String() string {
+	flag, value := f.sshFlag()
+	return flag + " " + value
+}
+
+// validate checks the listen and target addresses of the forward
+func (f PortForward) validate() error {
+	if _, err := listenAddress(f.Listen); err != nil {
+		return err
+	}
+	if f.Type == forwardDynamic {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(f.Target); err != nil || port == "" {
+		return errors.New(currentLang["msg_forward_target"])
+	}
+	return nil
+}
+
+// listenAddress converts [bind_address:]port to host:port, binding to loopback by default like ssh
+func listenAddress(listen string) (string, error) {
+	host, port := "127.0.0.1", listen
+	if strings.Contains(listen, ":") {
+		var err error
+		if host, port, err = net.SplitHostPort(listen); err != nil {
+			return "", errors.New(currentLang["msg_forward_listen"])
+		}
+		if host == "" || host == "*" {
+			host = "0.0.0.0"
+		}
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return "", errors.New(currentLang["msg_forward_listen"])
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// forwardArgs returns the ssh command line arguments for the forwards
+func forwardArgs(forwards []PortForward) []string {
+	var args []string
+	for _, forward := range forwards {
+		flag, value := forward.sshFlag()
+		args = append(args, flag, value)
+	}
+	return args
+}
+
+// startNativeForwards opens the forwards over a built-in client connection
+// Returns a function closing all listeners
+func startNativeForwards(client *ssh.Client, forwards []PortForward) (func(), error) {
+	var listeners []net.Listener
+	stop := func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}
+
+	for _, forward := range forwards {
+		address, err := listenAddress(forward.Listen)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+
+		var listener net.Listener
+		var dial func(conn net.Conn) (net.Conn, error)
+		switch forward.Type {
+		case forwardRemote:
+			listener, err = client.Listen("tcp", address)
+			target := forward.Target
+			dial = func(net.Conn) (net.Conn, error) { return net.Dial("tcp", target) }
+		case forwardDynamic:
+			listener, err = net.Listen("tcp", address)
+			dial = func(conn net.Conn) (net.Conn, error) { return socksConnect(client, conn) }
+		default:
+			listener, err = net.Listen("tcp", address)
+			target := forward.Target
+			dial = func(net.Conn) (net.Conn, error) { return client.Dial("tcp", target) }
+		}
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("%s: %w", forward, err)
+		}
+		listeners = append(listeners, listener)
+		go serveForward(listener, dial)
+	}
+	return stop, nil
+}
+
+// serveForward accepts connections until the listener is closed and pipes each to its dialed peer
+func serveForward(listener net.Listener, dial func(conn net.Conn) (net.Conn, error)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			peer, err := dial(conn)
+			if err != nil {
+				return
+			}
+			defer peer.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				_, _ = io.Copy(peer, conn)
+				done <- struct{}{}
+			}()
+			go func() {
+				_, _ = io.Copy(conn, peer)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
+
+// socksConnect performs a minimal SOCKS5 handshake (no authentication, CONNECT only)
+// and dials the requested address through the SSH connection
+func socksConnect(client *ssh.Client, conn net.Conn) (net.Conn, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 5 {
+		return nil, errors.New("socks: unsupported version")
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return nil, err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, err
+	}
+	if request[1] != 1 {
+		_, _ = conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, errors.New("socks: unsupported command")
+	}
+
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if request[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = ip.String()
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return nil, err
+		}
+		host = string(name)
+	default:
+		return nil, errors.New("socks: unsupported address type")
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+
+	peer, err := client.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		peer.Close()
+		return nil, err
+	}
+	return peer, nil
+}
+
+// showForwards displays the port forwards of the connection with add, edit and delete actions
+func showForwards(app *tview.Application, connectionsList *tview.List, index int) {
+	if index < 0 || index >= len(sshConnections) {
+		return
+	}
+
+	forwards := tview.NewList().ShowSecondaryText(false)
+	forwards.SetTitle(fmt.Sprintf(currentLang["title_forwards"], sshConnections[index].Server)).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft)
+	forwards.SetBackgroundColor(tcell.ColorNavy)
+	forwards.SetMainTextColor(tcell.ColorWhite)
+	forwards.SetSelectedTextColor(tcell.ColorWhite)
+	forwards.SetSelectedBackgroundColor(tcell.ColorDarkRed)
+
+	if len(sshConnections[index].Forwards) == 0 {
+		forwards.AddItem(currentLang["msg_no_forwards"], "", 0, nil)
+	}
+	for i, forward := range sshConnections[index].Forwards {
+		forwardIndex := i
+		forwards.AddItem(fmt.Sprintf(" %-20s %s", tview.Escape(forward.Name), forward), "", 0, func() {
+			editForward(app, connectionsList, index, forwardIndex)
+		})
+	}
+
+	forwards.SetDoneFunc(func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	})
+	forwards.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyCtrlN, tcell.KeyInsert:
+			editForward(app, connectionsList, index, -1)
+			return nil
+		case tcell.KeyDelete:
+			current := forwards.GetCurrentItem()
+			if current >= 0 && current < len(sshConnections[index].Forwards) {
+				before := sshConnections[index]
+				beforeAll := journalSnapshot()
+				connForwards := before.Forwards
+				sshConnections[index].Forwards = append(connForwards[:current:current], connForwards[current+1:]...)
+				saveConnections()
+				auditChange(auditEdit, &before, &sshConnections[index])
+				recordOperation(fmt.Sprintf(currentLang["op_edit"], before.Server), beforeAll)
+				showForwards(app, connectionsList, index)
+			}
+			return nil
+		}
+		return event
+	})
+
+	hint := tview.NewTextView().SetText(currentLang["forwards_help"])
+	hint.SetBackgroundColor(tcell.ColorNavy)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(forwards, 0, 1, true).
+		AddItem(hint, 1, 0, false)
+	app.SetRoot(centerWidget(app, layout), true)
+}
+
+// editForward displays a form for the forward at forwardIndex, -1 adds a new forward
+func editForward(app *tview.Application, connectionsList *tview.List, index, forwardIndex int) {
+	var forward PortForward
+	if forwardIndex >= 0 {
+		forward = sshConnections[index].Forwards[forwardIndex]
+	}
+	backToForwards := func() {
+		showForwards(app, connectionsList, index)
+	}
+
+	types, typeLabels := forwardTypes()
+	typeIndex := 0
+	for i, forwardType := range types {
+		if forwardType == forward.Type {
+			typeIndex = i
+		}
+	}
+
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+	form := newStyledForm()
+	form.
+		AddInputField(currentLang["form_name"], forward.Name, 30, nil, nil).
+		AddDropDown(currentLang["form_forward_type"], typeLabels, typeIndex, nil).
+		AddInputField(currentLang["form_forward_listen"], forward.Listen, 25, nil, nil).
+		AddInputField(currentLang["form_forward_target"], forward.Target, 30, nil, nil).
+		AddButton(currentLang["btn_save"], func() {
+			selectedType, _ := form.GetFormItem(1).(*tview.DropDown).GetCurrentOption()
+			updated := PortForward{
+				Name:   strings.TrimSpace(form.GetFormItem(0).(*tview.InputField).GetText()),
+				Type:   types[selectedType],
+				Listen: strings.TrimSpace(form.GetFormItem(2).(*tview.InputField).GetText()),
+				Target: strings.TrimSpace(form.GetFormItem(3).(*tview.InputField).GetText()),
+			}
+			if updated.Type == forwardDynamic {
+				updated.Target = ""
+			}
+			if updated.Name == "" {
+				updated.Name = updated.String()
+			}
+			if err := updated.validate(); err != nil {
+				errorText.SetText(err.Error())
+				return
+			}
+
+			beforeAll := journalSnapshot()
+			before := beforeAll.Connections[index]
+			if forwardIndex >= 0 {
+				sshConnections[index].Forwards[forwardIndex] = updated
+			} else {
+				sshConnections[index].Forwards = append(sshConnections[index].Forwards, updated)
+			}
+			saveConnections()
+			auditChange(auditEdit, &before, &sshConnections[index])
+			recordOperation(fmt.Sprintf(currentLang["op_edit"], before.Server), beforeAll)
+			backToForwards()
+		}).
+		AddButton(currentLang["btn_cancel"], backToForwards)
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(errorText, 1, 0, false)
+
+	formFlex.SetBorder(true).
+		SetTitle(currentLang["title_forward"]).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerForm(app, formFlex, form), true)
+	app.SetFocus(form)
+}
@@ -0,0 +1,25 @@
+/*
+* Connection groups
+ */
+package main
+
+// Group holds settings shared by the connections that reference it by name
+type Group struct {
+	Name               string `json:"name"`
+	Record             bool   `json:"record,omitempty"` // record sessions of all members
+	Hooks              *Hooks `json:"hooks,omitempty"`
+	ConnectionDefaults        // settings of members that leave them empty
+}
+
+// findGroup returns the group with the given name from the config
+func findGroup(name string) (Group, bool) {
+	if name == "" {
+		return Group{}, false
+	}
+	for _, group := range config.Groups {
+		if group.Name == name {
+			return group, true
+		}
+	}
+	return Group{}, false
+}
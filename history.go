@@ -0,0 +1,182 @@
+/*
+* Connection history
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+)
+
+// History actions
+const (
+	historyConnect = "connect"
+	historyCopy    = "copy"
+	historyTunnel  = "tunnel"
+	historyRun     = "run"
+	historyDeploy  = "deploy"
+)
+
+// Number of entries shown in the history view
+const historyViewSize = 50
+
+// Size after which the history file is moved to history.jsonl.1 and started anew
+const historyMaxSize = 1 << 20
+
+var historyFilePath = filepath.Join(configDir, "history.jsonl")
+
+// HistoryEntry is one line of the history file
+type HistoryEntry struct {
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	Action   string    `json:"action"`
+	Details  string    `json:"details,omitempty"`
+	Duration string    `json:"duration"`
+	ExitCode int       `json:"exit_code"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// exitCode returns the exit status of a finished session or command, -1 if it did not run
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var sshExitErr *ssh.ExitError
+	if errors.As(err, &sshExitErr) {
+		return sshExitErr.ExitStatus()
+	}
+	return -1
+}
+
+// recordHistory appends the result of an action to the history file
+func recordHistory(server, action, details string, started time.Time, err error) {
+	entry := HistoryEntry{
+		Time:     started,
+		Server:   server,
+		Action:   action,
+		Details:  details,
+		Duration: time.Since(started).Round(time.Second).String(),
+		ExitCode: exitCode(err),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		log.Printf(currentLang["msg_config_dir_error"], err)
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf(currentLang["msg_history_error"], err)
+		return
+	}
+
+	if info, err := os.Stat(historyFilePath); err == nil && info.Size() >= historyMaxSize {
+		if err := os.Rename(historyFilePath, historyFilePath+".1"); err != nil {
+			log.Printf(currentLang["msg_history_error"], err)
+		}
+	}
+	file, err := os.OpenFile(historyFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf(currentLang["msg_history_error"], err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(sealLine(data), '\n')); err != nil {
+		log.Printf(currentLang["msg_history_error"], err)
+	}
+}
+
+// loadHistory returns the history entries for the server, newest first
+func loadHistory(server string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	for _, path := range []string{historyFilePath + ".1", historyFilePath} {
+		var err error
+		if entries, err = readHistoryFile(path, server, entries); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// readHistoryFile appends the entries of the server found in the file, in file order
+func readHistoryFile(path, server string, entries []HistoryEntry) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, err := openLine(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		var entry HistoryEntry
+		if json.Unmarshal(line, &entry) != nil || entry.Server != server {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// showHistory displays the latest history entries of the server
+func showHistory(app *tview.Application, connectionsList *tview.List, server string) {
+	entries, err := loadHistory(server)
+	if err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_history_error"], err))
+		return
+	}
+	if len(entries) > historyViewSize {
+		entries = entries[:historyViewSize]
+	}
+
+	var text strings.Builder
+	if len(entries) == 0 {
+		text.WriteString(currentLang["msg_no_history"])
+	}
+	for _, entry := range entries {
+		color := "green"
+		if entry.ExitCode != 0 {
+			color = "red"
+		}
+		fmt.Fprintf(&text, " %s  %-8s [%s]%4d[-]  %8s  %s\n",
+			entry.Time.Format("2006-01-02 15:04"), entry.Action, color, entry.ExitCode,
+			entry.Duration, tview.Escape(entry.Details))
+	}
+
+	view := tview.NewTextView().SetDynamicColors(true).SetText(text.String())
+	view.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_history"], server)).
+		SetTitleAlign(tview.AlignLeft)
+	view.SetBackgroundColor(tcell.ColorNavy)
+	view.SetDoneFunc(func(key tcell.Key) {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	})
+	app.SetRoot(centerWidget(app, view), true)
+}
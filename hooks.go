@@ -0,0 +1,126 @@
+/*
+* Pre- and post-connect hooks
+ */
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Hook stages
+const (
+	hookPreConnect  = "pre_connect"
+	hookPostConnect = "post_connect"
+	// Renews the SSH certificate before connecting, only the most specific command runs
+	hookRenewCertificate = "renew_certificate"
+)
+
+var hooksLogPath = filepath.Join(configDir, "hooks.log")
+
+// Hooks are shell commands run around sessions, set globally, per group or per connection
+type Hooks struct {
+	PreConnect       string `json:"pre_connect,omitempty"`       // a non-zero exit aborts the connect
+	PostConnect      string `json:"post_connect,omitempty"`      // runs after the session ends
+	RenewCertificate string `json:"renew_certificate,omitempty"` // runs when the certificate is missing or expiring
+}
+
+// command returns the hook command of the stage
+func (h *Hooks) command(stage string) string {
+	if h == nil {
+		return ""
+	}
+	switch stage {
+	case hookPreConnect:
+		return h.PreConnect
+	case hookRenewCertificate:
+		return h.RenewCertificate
+	}
+	return h.PostConnect
+}
+
+// hookCommands returns the hook commands of the connection for the stage
+// Pre-connect hooks run from the global to the connection level, post-connect hooks in reverse
+func hookCommands(conn SSHConnection, stage string) []string {
+	levels := []*Hooks{config.Hooks}
+	if group, ok := findGroup(conn.Group); ok {
+		levels = append(levels, group.Hooks)
+	}
+	levels = append(levels, conn.Hooks)
+	if stage == hookPostConnect || stage == hookRenewCertificate {
+		for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+			levels[i], levels[j] = levels[j], levels[i]
+		}
+	}
+
+	var commands []string
+	for _, hooks := range levels {
+		if command := hooks.command(stage); command != "" {
+			commands = append(commands, command)
+			if stage == hookRenewCertificate {
+				break
+			}
+		}
+	}
+	return commands
+}
+
+// hookEnv returns the environment of hooks with the connection metadata
+// exitCode is the session exit code passed to post-connect hooks
+func hookEnv(conn SSHConnection, stage string, exitCode int) []string {
+	env := append(os.Environ(),
+		"SSHMAN_HOOK="+stage,
+		"SSHMAN_SERVER="+conn.Server,
+		"SSHMAN_PORT="+conn.Port,
+		"SSHMAN_USER="+connectionUser(conn),
+		"SSHMAN_COMMENT="+conn.Comment,
+		"SSHMAN_IDENTITY_FILE="+expandHome(conn.IdentityFile),
+		"SSHMAN_JUMP_HOST="+conn.JumpHost,
+		"SSHMAN_GROUP="+conn.Group,
+		"SSHMAN_CERT_FILE="+certificatePath(conn),
+	)
+	if stage == hookPostConnect {
+		env = append(env, "SSHMAN_EXIT_CODE="+strconv.Itoa(exitCode))
+	}
+	return env
+}
+
+// runHooks runs the hooks of the stage in the terminal, appending their output to the hooks log
+// Stops at the first failing hook and returns its error
+func runHooks(conn SSHConnection, stage string, exitCode int) error {
+	commands := hookCommands(conn, stage)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	output := io.Writer(os.Stdout)
+	errorOutput := io.Writer(os.Stderr)
+	if err := os.MkdirAll(configDir, 0755); err == nil {
+		if logFile, err := os.OpenFile(hooksLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600); err == nil {
+			defer logFile.Close()
+			// Sealed line by line while the config is encrypted
+			sealed := &sealedWriter{writer: logFile}
+			defer sealed.Close()
+			output = io.MultiWriter(os.Stdout, sealed)
+			errorOutput = io.MultiWriter(os.Stderr, sealed)
+		}
+	}
+
+	for _, command := range commands {
+		fmt.Fprintf(output, "%s %s %s: %s\n", time.Now().Format(time.RFC3339), conn.Server, stage, command)
+		cmd := shellExec(command)
+		cmd.Env = hookEnv(conn, stage, exitCode)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = output
+		cmd.Stderr = errorOutput
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(errorOutput, "%s %s %s: %v\n", time.Now().Format(time.RFC3339), conn.Server, stage, err)
+			return fmt.Errorf(currentLang["msg_hook_failed"], stage, command, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+/*
+* Undo and redo journal of connection list changes
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Number of operations kept for undo
+const journalSize = 50
+
+var journalFilePath = filepath.Join(configDir, "journal.json")
+
+// JournalState is the connections list and the trash at one point of the journal
+type JournalState struct {
+	Connections []SSHConnection     `json:"connections"`
+	Trash       []TrashedConnection `json:"trash,omitempty"`
+}
+
+// JournalOperation is one undoable change: the state before and after it
+type JournalOperation struct {
+	Label  string       `json:"label"`
+	Before JournalState `json:"before"`
+	After  JournalState `json:"after"`
+}
+
+// Journal holds the operations that can be undone and redone, newest last
+type Journal struct {
+	Undo []JournalOperation `json:"undo"`
+	Redo []JournalOperation `json:"redo"`
+}
+
+var (
+	journal    Journal
+	statusText *tview.TextView // result of the last undo or redo above the help text
+)
+
+// journalSnapshot returns a deep copy of the current connections and trash, so later
+// in-place edits of forwards or variables do not reach the journal
+func journalSnapshot() JournalState {
+	state := JournalState{Connections: sshConnections, Trash: config.Trash}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return state
+	}
+	var copied JournalState
+	_ = json.Unmarshal(data, &copied)
+	return copied
+}
+
+// sameState reports whether both states hold the same connections in the same order
+func sameState(a, b JournalState) bool {
+	// An empty list may be saved as null
+	if len(a.Connections) == 0 {
+		a.Connections = nil
+	}
+	if len(b.Connections) == 0 {
+		b.Connections = nil
+	}
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// referencedCredentials returns the secrets the connections, the trash and every state of
+// the journal refer to; undo and redo need them in the store
+func referencedCredentials() map[credentialKey]bool {
+	keys := make(map[credentialKey]bool)
+	addCredentialKeys(keys, sshConnections)
+	for _, trashed := range config.Trash {
+		addCredentialKeys(keys, []SSHConnection{trashed.SSHConnection})
+	}
+	for _, ops := range [][]JournalOperation{journal.Undo, journal.Redo} {
+		for _, op := range ops {
+			addCredentialKeys(keys, op.Before.Connections)
+			addCredentialKeys(keys, op.After.Connections)
+			for _, trashed := range append(op.Before.Trash, op.After.Trash...) {
+				addCredentialKeys(keys, []SSHConnection{trashed.SSHConnection})
+			}
+		}
+	}
+	return keys
+}
+
+// purgeFromJournal removes a purged connection from the trash of every journal state and
+// drops the operations that would bring it back: those holding it as a connection and,
+// because operations apply only in order, all undone or redone after them
+func purgeFromJournal(trashed TrashedConnection) {
+	purged, _ := json.Marshal(trashed.SSHConnection)
+	prune := func(ops []JournalOperation) []JournalOperation {
+		keep := 0
+		for i := range ops {
+			for _, state := range []*JournalState{&ops[i].Before, &ops[i].After} {
+				var trash []TrashedConnection
+				for _, item := range state.Trash {
+					if item.Server != trashed.Server || !item.Deleted.Equal(trashed.Deleted) {
+						trash = append(trash, item)
+					}
+				}
+				state.Trash = trash
+				for _, conn := range state.Connections {
+					if data, err := json.Marshal(conn); err == nil && bytes.Equal(data, purged) {
+						keep = i + 1
+					}
+				}
+			}
+		}
+		return ops[keep:]
+	}
+	journal.Undo = prune(journal.Undo)
+	journal.Redo = prune(journal.Redo)
+	saveJournal()
+}
+
+// recordOperation adds the change from before to the current state to the journal
+// and drops the operations that could be redone, with the secrets only they referred to
+func recordOperation(label string, before JournalState) {
+	after := journalSnapshot()
+	if sameState(before, after) {
+		return
+	}
+	released := referencedCredentials()
+	addCredentialKeys(released, before.Connections)
+	defer dropUnreferencedCredentials(released)
+
+	journal.Undo = append(journal.Undo, JournalOperation{
+		Label:  label,
+		Before: before,
+		After:  after,
+	})
+	if len(journal.Undo) > journalSize {
+		journal.Undo = journal.Undo[len(journal.Undo)-journalSize:]
+	}
+	journal.Redo = nil
+	saveJournal()
+}
+
+// applyOperation replaces the connections and trash with a journal state and saves them
+func applyOperation(app *tview.Application, connectionsList *tview.List, from, to JournalState) {
+	restored, _ := json.Marshal(to)
+	var state JournalState
+	_ = json.Unmarshal(restored, &state)
+	sshConnections = state.Connections
+	config.Trash = state.Trash
+	for server := range selected {
+		if _, ok := findConnection(server); !ok {
+			delete(selected, server)
+		}
+	}
+	saveConnections()
+	auditConnections(from.Connections, sshConnections)
+	// The trash item shows the number of trashed connections
+	populateMenu(app, connectionsList)
+	refreshConnectionsList(app, connectionsList, connectionsList.GetCurrentItem())
+	checkHostsOnline(app, connectionsList, sshConnections)
+	// The list height follows the number of connections
+	app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	app.SetFocus(connectionsList)
+}
+
+// undoOperation reverts the last operation and reports it in the status line
+func undoOperation(app *tview.Application, connectionsList *tview.List) {
+	if len(journal.Undo) == 0 {
+		setStatus(currentLang["status_nothing_undo"], tcell.ColorYellow)
+		return
+	}
+	op := journal.Undo[len(journal.Undo)-1]
+	if !sameState(op.After, journalSnapshot()) {
+		discardJournal()
+		return
+	}
+	journal.Undo = journal.Undo[:len(journal.Undo)-1]
+	journal.Redo = append(journal.Redo, op)
+	applyOperation(app, connectionsList, op.After, op.Before)
+	saveJournal()
+	setStatus(fmt.Sprintf(currentLang["status_undone"], op.Label), tcell.ColorWhite)
+}
+
+// redoOperation applies the last undone operation again
+func redoOperation(app *tview.Application, connectionsList *tview.List) {
+	if len(journal.Redo) == 0 {
+		setStatus(currentLang["status_nothing_redo"], tcell.ColorYellow)
+		return
+	}
+	op := journal.Redo[len(journal.Redo)-1]
+	if !sameState(op.Before, journalSnapshot()) {
+		discardJournal()
+		return
+	}
+	journal.Redo = journal.Redo[:len(journal.Redo)-1]
+	journal.Undo = append(journal.Undo, op)
+	applyOperation(app, connectionsList, op.Before, op.After)
+	saveJournal()
+	setStatus(fmt.Sprintf(currentLang["status_redone"], op.Label), tcell.ColorWhite)
+}
+
+// discardJournal forgets the journal when the connections no longer match it, after
+// the config file was edited outside sshman
+func discardJournal() {
+	forgetJournal()
+	setStatus(currentLang["status_journal_stale"], tcell.ColorYellow)
+}
+
+// forgetJournal empties the journal and clears the secrets only it referred to
+func forgetJournal() {
+	released := referencedCredentials()
+	journal = Journal{}
+	saveJournal()
+	dropUnreferencedCredentials(released)
+}
+
+// closeJournal ends the journal of the session when the UI exits or locks; a journal
+// that is not persisted takes the secrets only it referred to along
+func closeJournal() {
+	if config.PersistUndo {
+		journal = Journal{}
+		return
+	}
+	forgetJournal()
+}
+
+// forgetCredentialInJournal removes a cleared secret from every journal state, so undo
+// never restores a reference to it
+func forgetCredentialInJournal(key credentialKey) {
+	for _, ops := range [][]JournalOperation{journal.Undo, journal.Redo} {
+		for i := range ops {
+			for _, state := range []*JournalState{&ops[i].Before, &ops[i].After} {
+				for j := range state.Connections {
+					if (credentialKey{state.Connections[j].Credential, state.Connections[j].Server}) == key {
+						state.Connections[j].Credential = ""
+					}
+				}
+			}
+		}
+	}
+	saveJournal()
+}
+
+// setStatus shows a message in the status line
+func setStatus(message string, color tcell.Color) {
+	statusText.SetTextColor(color)
+	statusText.SetText(" " + message)
+}
+
+// saveJournal writes the journal when persist_undo is enabled, encrypted like the config
+func saveJournal() {
+	if !config.PersistUndo || configLocked {
+		return
+	}
+	data, err := json.Marshal(journal)
+	if err == nil {
+		data, err = sealConfigFile(data)
+	}
+	if err == nil {
+		err = os.WriteFile(journalFilePath, data, 0600)
+	}
+	if err != nil {
+		log.Printf(currentLang["msg_journal_error"], err)
+	}
+}
+
+// loadJournal restores the journal of a previous session when persist_undo is enabled
+// A journal that does not end at the saved connections is ignored
+func loadJournal() {
+	journal = Journal{}
+	// A locked config has no connections to compare the journal with yet
+	if !config.PersistUndo || configLocked {
+		return
+	}
+	data, err := os.ReadFile(journalFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf(currentLang["msg_journal_error"], err)
+		}
+		return
+	}
+	if envelope, encrypted := parseEncryptedConfig(data); encrypted {
+		if data, err = envelope.decrypt(configPassphrase); err != nil {
+			log.Printf(currentLang["msg_journal_error"], err)
+			return
+		}
+	}
+
+	var saved Journal
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf(currentLang["msg_journal_error"], err)
+		return
+	}
+	current := journalSnapshot()
+	if n := len(saved.Undo); n > 0 {
+		current = saved.Undo[n-1].After
+	} else if n := len(saved.Redo); n > 0 {
+		current = saved.Redo[n-1].Before
+	}
+	if sameState(current, journalSnapshot()) {
+		journal = saved
+		return
+	}
+	// The secrets of a journal that no longer applies are not needed
+	journal = saved
+	forgetJournal()
+}
+
+// moveConnection moves the connection at the index up or down the list by delta
+func moveConnection(app *tview.Application, connectionsList *tview.List, index, delta int) {
+	target := index + delta
+	if index < 0 || index >= len(sshConnections) || target < 0 || target >= len(sshConnections) {
+		return
+	}
+	before := journalSnapshot()
+	sshConnections[index], sshConnections[target] = sshConnections[target], sshConnections[index]
+	saveConnections()
+	recordOperation(fmt.Sprintf(currentLang["op_move"], sshConnections[target].Server), before)
+	refreshConnectionsList(app, connectionsList, target)
+}
@@ -0,0 +1,415 @@
+/*
+* SSH key pairs in ~/.ssh: listing, generation and usage by connections
+ */
+package main
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/crypto/ssh"
+)
+
+// Key types offered when generating a key
+const (
+	keyTypeEd25519 = "ed25519"
+	keyTypeRSA     = "rsa"
+)
+
+// Key sizes
+const (
+	rsaMinBits     = 2048 // shorter RSA keys are flagged as weak
+	rsaDefaultBits = 4096
+)
+
+// sshKey is a key pair found in ~/.ssh
+type sshKey struct {
+	path        string // private key, may be missing
+	publicPath  string
+	keyType     string
+	bits        int
+	fingerprint string
+	comment     string
+	hasPrivate  bool
+	users       []string // connections with this identity file
+	defaultFor  []string // connections without an identity file, for default key names
+}
+
+// sshDir returns the ssh configuration directory of the user
+func sshDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh")
+}
+
+// publicKeyBits returns the size of the public key in bits
+func publicKeyBits(public ssh.PublicKey) int {
+	cryptoKey, ok := public.(ssh.CryptoPublicKey)
+	if !ok {
+		// Security key types carry no crypto.PublicKey
+		return 256
+	}
+	switch key := cryptoKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case *dsa.PublicKey:
+		return key.P.BitLen()
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
+
+// weakness returns why the key is considered weak, empty when it is not
+func (key sshKey) weakness() string {
+	switch {
+	case key.keyType == ssh.KeyAlgoDSA:
+		return currentLang["key_weak_dsa"]
+	case key.keyType == ssh.KeyAlgoRSA && key.bits < rsaMinBits:
+		return fmt.Sprintf(currentLang["key_weak_rsa"], key.bits, rsaMinBits)
+	}
+	return ""
+}
+
+// unused reports whether no connection uses the key
+func (key sshKey) unused() bool {
+	return len(key.users) == 0 && len(key.defaultFor) == 0
+}
+
+// identityPath returns the cleaned path of an identity file setting
+func identityPath(identity string) string {
+	return filepath.Clean(expandHome(identity))
+}
+
+// loadKeys reads the public keys in ~/.ssh and the connections using each key
+func loadKeys() ([]sshKey, error) {
+	paths, err := filepath.Glob(filepath.Join(sshDir(), "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys set as group defaults count for the members using them
+	connections := resolveConnections(sshConnections)
+	var keys []sshKey
+	for _, publicPath := range paths {
+		if strings.HasSuffix(publicPath, "-cert.pub") {
+			continue
+		}
+		data, err := os.ReadFile(publicPath)
+		if err != nil {
+			continue
+		}
+		public, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			continue
+		}
+
+		key := sshKey{
+			path:        strings.TrimSuffix(publicPath, ".pub"),
+			publicPath:  publicPath,
+			keyType:     public.Type(),
+			bits:        publicKeyBits(public),
+			fingerprint: ssh.FingerprintSHA256(public),
+			comment:     comment,
+		}
+		_, err = os.Stat(key.path)
+		key.hasPrivate = err == nil
+
+		isDefault := false
+		for _, name := range defaultIdentityFiles {
+			if filepath.Base(key.path) == name {
+				isDefault = true
+			}
+		}
+		for _, conn := range connections {
+			if conn.IdentityFile == "" {
+				if isDefault {
+					key.defaultFor = append(key.defaultFor, conn.Server)
+				}
+			} else if identityPath(conn.IdentityFile) == key.path {
+				key.users = append(key.users, conn.Server)
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].path < keys[j].path
+	})
+	return keys, nil
+}
+
+// generateKey writes a new key pair in OpenSSH format, encrypting the private key when
+// a passphrase is given. Existing files are never overwritten
+func generateKey(keyType string, bits int, path, comment, passphrase string) error {
+	var private crypto.Signer
+	var err error
+	switch keyType {
+	case keyTypeEd25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case keyTypeRSA:
+		if bits < rsaMinBits {
+			return fmt.Errorf(currentLang["msg_key_bits"], rsaMinBits)
+		}
+		private, err = rsa.GenerateKey(rand.Reader, bits)
+	default:
+		return fmt.Errorf(currentLang["msg_key_type"], keyType)
+	}
+	if err != nil {
+		return err
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(private, comment)
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, comment, []byte(passphrase))
+	}
+	if err != nil {
+		return err
+	}
+	public, err := ssh.NewPublicKey(private.Public())
+	if err != nil {
+		return err
+	}
+	publicLine := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(public)))
+	if comment != "" {
+		publicLine += " " + comment
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := writeNewFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return err
+	}
+	if err := writeNewFile(path+".pub", []byte(publicLine+"\n"), 0644); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// writeNewFile creates the file with the data, failing if it already exists
+func writeNewFile(path string, data []byte, mode os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+// newKeyName returns the first id_<type> file name not taken in ~/.ssh
+func newKeyName(keyType string) string {
+	name := "id_" + keyType
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(sshDir(), name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("id_%s_%d", keyType, i)
+	}
+}
+
+// localUser returns user@host of the person running sshman, also the default key comment like ssh-keygen uses
+func localUser() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
+
+// keyLine formats a key for the keys list
+func keyLine(key sshKey) string {
+	keyType := strings.TrimPrefix(key.keyType, "ssh-")
+	line := fmt.Sprintf(" %-20s %-20s %5d  %s", tview.Escape(filepath.Base(key.path)),
+		tview.Escape(keyType), key.bits, tview.Escape(key.comment))
+	if key.weakness() != "" {
+		line += "  [red]" + currentLang["key_flag_weak"] + "[-]"
+	}
+	if key.unused() {
+		line += "  [yellow]" + currentLang["key_flag_unused"] + "[-]"
+	}
+	if !key.hasPrivate {
+		line += "  [yellow]" + currentLang["key_flag_no_private"] + "[-]"
+	}
+	return line
+}
+
+// keyDetails formats the fingerprint, files, users and warnings of a key
+func keyDetails(key sshKey) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, " [yellow]%-16s[-] %s\n", currentLang["key_fingerprint"]+":", key.fingerprint)
+	fmt.Fprintf(&text, " [yellow]%-16s[-] %s\n", currentLang["key_file"]+":", tview.Escape(key.path))
+	users := strings.Join(key.users, ", ")
+	if len(key.defaultFor) > 0 {
+		if users != "" {
+			users += ", "
+		}
+		users += fmt.Sprintf(currentLang["key_default_for"], strings.Join(key.defaultFor, ", "))
+	}
+	if users == "" {
+		users = currentLang["key_no_users"]
+	}
+	fmt.Fprintf(&text, " [yellow]%-16s[-] %s\n", currentLang["key_used_by"]+":", tview.Escape(users))
+	if weakness := key.weakness(); weakness != "" {
+		fmt.Fprintf(&text, " [red]%s[-]\n", weakness)
+	}
+	return text.String()
+}
+
+// showKeys displays the key pairs in ~/.ssh with the connections using them
+func showKeys(app *tview.Application, connectionsList *tview.List, selectedPath string) {
+	keys, err := loadKeys()
+	if err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_key_error"], err))
+		return
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetTitle(fmt.Sprintf(currentLang["title_keys"], sshDir())).SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	list.SetUseStyleTags(true, false)
+	list.SetBackgroundColor(tcell.ColorNavy)
+	list.SetMainTextColor(tcell.ColorWhite)
+	list.SetSelectedTextColor(tcell.ColorWhite)
+	list.SetSelectedBackgroundColor(tcell.ColorDarkRed)
+
+	details := tview.NewTextView().SetDynamicColors(true)
+	details.SetBorder(true)
+	details.SetBackgroundColor(tcell.ColorNavy)
+
+	if len(keys) == 0 {
+		list.AddItem(currentLang["msg_no_keys"], "", 0, nil)
+	}
+	current := 0
+	for i, key := range keys {
+		list.AddItem(keyLine(key), "", 0, nil)
+		if key.path == selectedPath {
+			current = i
+		}
+	}
+	list.SetChangedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if index < len(keys) {
+			details.SetText(keyDetails(keys[index]))
+		}
+	})
+	list.SetCurrentItem(current)
+	if current < len(keys) {
+		details.SetText(keyDetails(keys[current]))
+	}
+
+	list.SetDoneFunc(func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	})
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == 'n' {
+			generateKeyForm(app, connectionsList)
+			return nil
+		}
+		return event
+	})
+
+	hint := tview.NewTextView().SetText(currentLang["keys_help"])
+	hint.SetBackgroundColor(tcell.ColorNavy)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(list, 0, 1, true).
+		AddItem(details, 6, 0, false).
+		AddItem(hint, 1, 0, false)
+	app.SetRoot(centerWidget(app, layout), true)
+}
+
+// generateKeyForm asks for the type, size, file, comment and passphrase of a new key
+func generateKeyForm(app *tview.Application, connectionsList *tview.List) {
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+	keyTypes := []string{keyTypeEd25519, keyTypeRSA}
+
+	form := newStyledForm()
+	form.
+		AddDropDown(currentLang["form_key_type"], keyTypes, 0, func(option string, index int) {
+			// Follow the type in the file name until it is edited
+			if form.GetFormItemCount() < 3 {
+				return
+			}
+			field := form.GetFormItemByLabel(currentLang["form_key_file"]).(*tview.InputField)
+			for _, keyType := range keyTypes {
+				if strings.HasPrefix(field.GetText(), "id_"+keyType) {
+					field.SetText(newKeyName(option))
+				}
+			}
+		}).
+		AddInputField(currentLang["form_key_bits"], strconv.Itoa(rsaDefaultBits), 6, tview.InputFieldInteger, nil).
+		AddInputField(currentLang["form_key_file"], newKeyName(keyTypeEd25519), 40, nil, nil).
+		AddInputField(currentLang["form_comment"], localUser(), 40, nil, nil).
+		AddPasswordField(currentLang["form_key_passphrase"], "", 30, '*', nil).
+		AddPasswordField(currentLang["form_key_confirm"], "", 30, '*', nil).
+		AddButton(currentLang["btn_generate"], func() {
+			text := func(label string) string {
+				return form.GetFormItemByLabel(currentLang[label]).(*tview.InputField).GetText()
+			}
+			_, keyType := form.GetFormItemByLabel(currentLang["form_key_type"]).(*tview.DropDown).GetCurrentOption()
+			bits, _ := strconv.Atoi(text("form_key_bits"))
+			path := expandHome(strings.TrimSpace(text("form_key_file")))
+			if path == "" {
+				errorText.SetText(currentLang["msg_enter_key_file"])
+				return
+			}
+			if !strings.ContainsRune(path, os.PathSeparator) {
+				path = filepath.Join(sshDir(), path)
+			}
+			if text("form_key_passphrase") != text("form_key_confirm") {
+				errorText.SetText(currentLang["msg_passphrase_mismatch"])
+				return
+			}
+
+			err := generateKey(keyType, bits, path, strings.TrimSpace(text("form_comment")), text("form_key_passphrase"))
+			if errors.Is(err, os.ErrExist) {
+				err = fmt.Errorf(currentLang["msg_key_exists"], path)
+			}
+			if err != nil {
+				errorText.SetText(err.Error())
+				return
+			}
+			showKeys(app, connectionsList, path)
+		}).
+		AddButton(currentLang["btn_cancel"], func() {
+			showKeys(app, connectionsList, "")
+		})
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(errorText, 1, 0, false)
+
+	formFlex.SetBorder(true).
+		SetTitle(currentLang["title_generate_key"]).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerForm(app, formFlex, form), true)
+	app.SetFocus(form)
+}
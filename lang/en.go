@@ -5,22 +5,79 @@ var EN = map[string]string{
 	"menu_title":        "Menu",
 	"connections_title": "Connections",
 	"menu_add":          "Add connection",
+	"menu_tunnels":      "Tunnels",
+	"menu_recordings":   "Recordings",
+	"menu_keys":         "SSH keys",
+	"menu_trash":        "Trash (%d)",
 	"menu_language":     "Language",
 	"menu_edit_config":  "Edit config",
 	"menu_exit":         "Exit",
 
 	// Buttons
-	"btn_ok":     "OK",
-	"btn_cancel": "Cancel",
-	"btn_save":   "Save",
+	"btn_ok":               "OK",
+	"btn_cancel":           "Cancel",
+	"btn_save":             "Save",
+	"btn_copy":             "Copy",
+	"btn_run":              "Run",
+	"btn_new_window":       "New window",
+	"btn_split_pane":       "Split pane",
+	"btn_clear_credential": "Clear credential",
+	"btn_terminal":         "Terminal",
+	"btn_unlock":           "Unlock",
+	"btn_generate":         "Generate",
+	"btn_deploy":           "Deploy",
+	"btn_preview":          "Preview",
+	"btn_apply":            "Apply",
+	"btn_back":             "Back",
+	"btn_agent_add":        "Add to agent",
+	"btn_connect":          "Connect",
 
 	// Forms
-	"form_server":   "SSH server",
-	"form_port":     "Port",
-	"form_comment":  "Comment",
-	"form_username": "Username",
-	"title_add":     "Add connection",
-	"title_edit":    "Edit connection",
+	"form_server":            "SSH server",
+	"form_port":              "Port",
+	"form_comment":           "Comment",
+	"form_username":          "Username",
+	"form_identity":          "Identity file",
+	"form_jump_host":         "Jump host",
+	"form_credential":        "Credential store",
+	"form_secret":            "Password/passphrase",
+	"credential_none":        "none",
+	"credential_keyring":     "system keyring",
+	"form_client":            "Client",
+	"form_transport":         "Transport",
+	"form_transport_options": "Transport options",
+	"transport_custom":       "custom",
+	"form_terminal":          "Terminal command",
+	"form_group":             "Group",
+	"form_template":          "Template",
+	"template_none":          "(none)",
+	"form_record":            "Record sessions",
+	"form_reconnect":         "Reconnect on network errors",
+	"form_remote_command":    "Remote command",
+	"form_request_tty":       "Request TTY",
+	"tty_default":            "default",
+	"form_set_env":           "SetEnv NAME=value",
+	"form_send_env":          "SendEnv patterns",
+	"client_exec":            "System ssh",
+	"client_native":          "Built-in",
+	"title_add":              "Add connection",
+	"title_clone":            "Clone connection %s",
+	"title_edit":             "Edit connection",
+	"form_name":              "Name",
+	"title_rename":           "Rename %s",
+	"title_unlock":           "Unlock configuration",
+	"title_generate_key":     "Generate key",
+	"title_deploy_key":       "Deploy public key to %d host(s)",
+	"title_bulk_edit":        "Bulk edit of %d connection(s)",
+	"title_bulk_preview":     "Changes to apply: %d",
+	"form_public_key":        "Public key",
+	"form_set_identity":      "Use as identity file",
+	"form_key_type":          "Type",
+	"form_key_bits":          "RSA bits",
+	"form_key_file":          "File",
+	"form_key_passphrase":    "Passphrase",
+	"form_key_confirm":       "Repeat passphrase",
+	"form_config_passphrase": "Passphrase",
 
 	// Messages
 	"msg_no_connections": "No saved connections",
@@ -30,20 +87,236 @@ var EN = map[string]string{
 	"msg_connecting":     "Connecting to %s\n",
 	"msg_conn_error":     "Connection error to %s: %v\n",
 
+	// Built-in client prompts
+	"prompt_passphrase":         "Enter passphrase for key %s: ",
+	"prompt_host_key":           "The authenticity of host %s can't be established.\n%s key fingerprint is %s.\nAre you sure you want to continue connecting (yes/no)? ",
+	"msg_host_key_rejected":     "host key verification failed",
+	"prompt_config_passphrase":  "Config passphrase: ",
+	"prompt_new_passphrase":     "New config passphrase: ",
+	"prompt_confirm_passphrase": "Repeat passphrase: ",
+
+	// SFTP browser
+	"sftp_local":     "Local",
+	"sftp_remote":    "Remote",
+	"sftp_help":      " Tab - Switch pane  Enter - Open  Backspace - Up  F5 - Copy  F6 - Rename  F8 - Delete  Esc - Close",
+	"msg_sftp_error": "SFTP error for %s: %v",
+	"msg_sftp_dir":   "Only files can be copied",
+	"msg_sftp_done":  "Copied %s",
+
+	// File copy
+	"title_copy":          "Copy files: %s",
+	"form_copy_tool":      "Tool",
+	"form_copy_direction": "Direction",
+	"form_copy_local":     "Local path",
+	"form_copy_remote":    "Remote path",
+	"form_copy_recursive": "Recursive",
+	"copy_upload":         "Upload (local → remote)",
+	"copy_download":       "Download (remote → local)",
+	"msg_enter_paths":     "Enter local and remote paths",
+	"msg_not_found":       "%s not found in PATH",
+	"msg_copy_error":      "Copy error for %s: %v\n",
+	"msg_copy_done":       "Copy finished",
+	"prompt_continue":     "Press Enter to continue...",
+
+	// History
+	"title_history":        "History: %s",
+	"msg_no_history":       " No history yet",
+	"msg_history_error":    "Error writing history: %v\n",
+	"msg_audit_error":      "Error writing audit log: %v\n",
+	"msg_journal_error":    "Error in undo journal: %v\n",
+	"op_add":               "add %s",
+	"op_edit":              "edit %s",
+	"op_delete":            "delete %s",
+	"op_move":              "move %s",
+	"op_set_identity":      "identity file of %d connections",
+	"status_undone":        "Undone: %s (Ctrl+Y - Redo)",
+	"status_redone":        "Redone: %s",
+	"status_nothing_undo":  "Nothing to undo",
+	"status_nothing_redo":  "Nothing to redo",
+	"status_journal_stale": "Connections were changed outside sshman, undo history cleared",
+	"msg_audit_since":      "Invalid -since value %q, expected a duration like 24h or a date like 2006-01-02",
+	"msg_audit_intact":     "Audit log intact: %d entries, last hash %s",
+	"msg_audit_broken":     "Audit log damaged: %d problems in %d entries",
+	"audit_bad_line":       "line %d: not an audit entry: %v",
+	"audit_bad_seq":        "line %d: sequence number %d out of order",
+	"audit_bad_link":       "line %d: previous hash does not match the line before",
+	"audit_bad_hash":       "line %d: hash does not match the entry, it was modified",
+
+	// Port forwards
+	"title_forwards":      "Port forwards: %s",
+	"title_forward":       "Port forward",
+	"forwards_help":       " Enter - Edit  Ctrl+N - Add  Del - Delete  Esc - Back",
+	"form_forward_type":   "Type",
+	"form_forward_listen": "Listen [address:]port",
+	"form_forward_target": "Target host:port",
+	"forward_local":       "Local (-L)",
+	"forward_remote":      "Remote (-R)",
+	"forward_dynamic":     "Dynamic SOCKS (-D)",
+	"msg_no_forwards":     " No port forwards",
+	"msg_forward_listen":  "Listen must be a port or address:port",
+	"msg_forward_target":  "Target must be host:port",
+
+	// Tunnels
+	"title_tunnels":          "Tunnels",
+	"tunnels_help":           " Enter/Del - Stop tunnel  Esc - Back",
+	"tunnels_server":         "Server",
+	"tunnels_forwards":       "Forwards",
+	"tunnels_status":         "Status",
+	"tunnels_running":        "running",
+	"tunnels_exited":         "exited",
+	"msg_no_forwards_for":    "No port forwards configured for %s",
+	"msg_tunnel_error":       "Tunnel error for %s: %v",
+	"tunnels_type":           "Type",
+	"tunnels_session":        "session",
+	"tunnels_persistent":     "persistent",
+	"msg_no_tunnels":         "No persistent tunnels",
+	"msg_tunnel_exists":      "A persistent tunnel for %s is already running",
+	"msg_tunnel_not_found":   "No persistent tunnel for %s",
+	"msg_tunnel_not_started": "The tunnel supervisor for %s did not start, see %s",
+	"msg_tunnel_retry":       "Tunnel to %s closed (%s), reconnecting in %s\n",
+	"msg_port_busy":          "local port %s is already in use",
+	"msg_keepalive_timeout":  "keepalive timeout",
+	"msg_conn_not_found":     "Connection %s not found",
+
+	// Run command
+	"title_run_command": "Run command on %d host(s)",
+	"title_hosts":       "Hosts",
+	"form_hosts":        "Hosts",
+	"form_command":      "Command",
+	"form_concurrency":  "Parallel sessions",
+	"msg_enter_command": "Enter command",
+	"run_summary":       " [green]%d ok[-]  [red]%d failed[-]  [yellow]%d running[-]  %d waiting    Tab - Output  Esc - Close",
+
+	// tmux and screen
+	"tmux_cluster_window":       "cluster",
+	"msg_no_multiplexer":        "not running inside tmux or screen",
+	"msg_tmux_only":             "panes are only supported inside tmux",
+	"msg_tmux_output":           "unexpected tmux output",
+	"msg_no_terminal":           "no terminal launcher configured",
+	"title_recordings":          "Recordings",
+	"recordings_help":           " Enter - Replay (Space - pause, q - stop)  Del - Delete  Esc - Back",
+	"msg_no_recordings":         "No recordings",
+	"title_trash":               "Trash",
+	"trash_help":                " Enter - Restore  Del - Purge  Esc - Back",
+	"msg_trash_empty":           "Trash is empty",
+	"trash_purge_in":            "purged in %d d",
+	"msg_restore_exists":        "A connection named %s already exists, rename it first",
+	"op_restore":                "restore %s",
+	"op_bulk_edit":              "bulk edit of %d connections",
+	"bulk_mixed":                "(different values)",
+	"bulk_mixed_clear":          "(different values, Ctrl+D clears them)",
+	"bulk_cleared":              "(cleared on all)",
+	"bulk_keep":                 "Keep: %s",
+	"bulk_empty":                "(empty)",
+	"bulk_on":                   "On",
+	"bulk_off":                  "Off",
+	"msg_bulk_nothing":          "No field was changed",
+	"msg_record_error":          "Recording error: %v\n",
+	"msg_record_unsupported":    "Recording the system ssh client is not supported on Windows, use the built-in client\n",
+	"msg_cast_version":          "not an asciicast v2 recording",
+	"msg_replay_done":           "End of recording, press any key",
+	"msg_hook_failed":           "%s hook %q failed: %w",
+	"msg_env_format":            "SetEnv must be space separated NAME=value pairs",
+	"msg_transport_fallback":    "%s not found in PATH, connecting with ssh\n",
+	"msg_reconnect_countdown":   "Connection to %s lost, reconnecting in %s (attempt %d of %d), press any key to cancel",
+	"history_reconnect":         "reconnect %d",
+	"msg_credential_store":      "unknown credential store %q",
+	"msg_credential_missing":    "no stored secret",
+	"msg_enter_secret":          "Enter the password or passphrase to store",
+	"msg_credential_error":      "Credential error: %v",
+	"msg_credential_cleared":    "Stored credential removed",
+	"title_details":             "Connection %s",
+	"details_yes":               "yes",
+	"details_forwards":          "Port forwards",
+	"details_pre_hooks":         "Pre-connect hooks",
+	"details_post_hooks":        "Post-connect hooks",
+	"details_renew_certificate": "Certificate renewal",
+	"details_certificate":       "Certificate",
+	"details_command":           "Command",
+	"msg_wrong_passphrase":      "wrong passphrase or damaged file",
+	"msg_config_locked":         "the configuration is encrypted, run from a terminal or set SSHMAN_CONFIG_PASSPHRASE",
+	"msg_sealed_line":           "line sealed with the key of an encrypted configuration that is not unlocked",
+	"msg_inherit_unsupported":   "background processes of an encrypted configuration are not supported on Windows",
+	"msg_config_kdf":            "unsupported key derivation %q",
+	"msg_config_invalid":        "%s is not valid JSON",
+	"msg_config_encrypted":      "the configuration is already encrypted",
+	"msg_config_not_encrypted":  "the configuration is not encrypted",
+	"msg_config_encrypted_edit": "The configuration is encrypted, run \"sshman config decrypt\" to edit it by hand\n",
+	"msg_passphrase_terminal":   "a terminal is needed to enter the passphrase",
+	"msg_empty_passphrase":      "the passphrase must not be empty",
+	"msg_passphrase_mismatch":   "passphrases do not match",
+	"title_keys":                "SSH keys in %s",
+	"keys_help":                 " n - Generate key  Esc - Back",
+	"msg_no_keys":               "No keys found",
+	"msg_key_error":             "Key error: %v",
+	"msg_key_bits":              "RSA keys need at least %d bits",
+	"msg_key_type":              "unknown key type %q",
+	"msg_enter_key_file":        "Enter the key file name",
+	"msg_key_exists":            "%s already exists",
+	"msg_not_certificate":       "%s is not an SSH certificate",
+	"cert_expired":              "cert expired",
+	"cert_not_yet_valid":        "cert not yet valid",
+	"cert_expiring":             "cert expires in %s",
+	"cert_forever":              "forever",
+	"cert_details":              "principals %s, valid %s - %s",
+	"msg_deploying":             "Deploying %s to %s\n",
+	"msg_deploy_error":          "Key deployment to %s failed: %v\n",
+	"msg_deploy_done":           "Key deployed to %d of %d host(s)\n",
+	"msg_agent_add_error":       "ssh-add failed: %v\n",
+	"agent_no_socket":           "ssh-agent: SSH_AUTH_SOCK not set",
+	"agent_unavailable":         "ssh-agent unavailable: %v",
+	"agent_keys":                "ssh-agent: %d key(s)",
+	"agent_more":                "... %d more",
+	"agent_key_loaded":          "in agent",
+	"agent_key_not_loaded":      "not in agent",
+	"agent_key_missing":         "key file missing",
+	"agent_no_lifetime":         "the agent lifetime",
+	"key_weak_dsa":              "Weak: DSA keys are insecure and disabled in current OpenSSH",
+	"key_weak_rsa":              "Weak: %d-bit RSA key, use at least %d bits",
+	"key_flag_weak":             "weak",
+	"key_flag_unused":           "unused",
+	"key_flag_no_private":       "no private key",
+	"key_fingerprint":           "Fingerprint",
+	"key_file":                  "File",
+	"key_used_by":               "Used by",
+	"key_default_for":           "%s (default identity)",
+	"key_no_users":              "no connection",
+
+	// Command line
+	"cli_usage":          "Usage:\n  sshman                          start the interactive UI\n  sshman connect <server>         open a session to a saved connection\n  sshman tunnels list             list persistent tunnels\n  sshman tunnels start <server>   start a persistent tunnel with the connection forwards\n  sshman tunnels stop <server>    stop a persistent tunnel\n  sshman config encrypt           encrypt the configuration with a passphrase\n  sshman config decrypt           store the configuration in plaintext again\n  sshman config read <file>       print a history, log or recording file of an encrypted config\n  sshman audit [-server S] [-action A] [-since 24h|DATE] [-json]\n                                  list the audit log\n  sshman audit verify             check the hash chain of the audit log\n",
+	"cli_tunnels_header": "SERVER\tPID\tSTATUS\tUPTIME\tATTEMPTS\tFORWARDS\tLAST ERROR",
+	"cli_audit_header":   "TIME\tUSER\tACTION\tSERVER\tEXIT\tCHANGES",
+
 	// Dialog messages
-	"dlg_connect": "Connect to %s?",
-	"dlg_edit":    "Edit connection %s?",
-	"dlg_delete":  "Delete connection %s?",
-	"dlg_add":     "Add new connection?",
+	"dlg_connect":     "Connect to %s?",
+	"dlg_edit":        "Edit connection %s?",
+	"dlg_delete":      "Move connection %s to the trash?",
+	"dlg_purge":       "Delete %s from the trash for good? Its stored password is removed too.",
+	"dlg_add":         "Add new connection?",
+	"dlg_delete_file": "Delete %s?",
+	"dlg_agent_add":   "Key %s is not loaded in ssh-agent. Add it for %s before connecting?",
 
 	// Context menu
-	"ctx_connect": "Connect",
-	"ctx_edit":    "Edit",
-	"ctx_cancel":  "Cancel",
-	"ctx_actions": "Actions for %s",
+	"ctx_connect":           "Connect",
+	"ctx_edit":              "Edit",
+	"ctx_clone":             "Clone",
+	"ctx_bulk_edit":         "Bulk edit (selected hosts)",
+	"ctx_details":           "Details",
+	"ctx_connect_forwards":  "Connect with forwards",
+	"ctx_start_tunnel":      "Start tunnel",
+	"ctx_persistent_tunnel": "Start persistent tunnel",
+	"ctx_forwards":          "Port forwards",
+	"ctx_sftp":              "SFTP browser",
+	"ctx_copy":              "Copy files",
+	"ctx_history":           "History",
+	"ctx_run_command":       "Run command (selected hosts)",
+	"ctx_deploy_key":        "Deploy public key (selected hosts)",
+	"ctx_sync_panes":        "Open selected in synchronized tmux panes",
+	"ctx_cancel":            "Cancel",
+	"ctx_actions":           "Actions for %s",
 
 	// Help text
-	"help_text": " Controls:                    \n ↑↓ - Navigate list           Tab - Switch section\n Enter - Connect              Ctrl+E - Edit connection\n Ctrl+N - Add connection      Del - Delete connection\n Ctrl+R - Refresh window      Ctrl+C - Exit",
+	"help_text": " Controls:                    \n ↑↓ - Navigate list           Tab - Switch section\n Enter - Connect              Ctrl+E - Edit connection\n Ctrl+N - Add connection      Del - Delete connection\n Ctrl+R - Refresh window      Ctrl+C - Exit\n Ctrl+O - Connection actions  Space - Select (+ all, - none)\n Ctrl+Z - Undo  Ctrl+Y - Redo  Shift+↑↓ - Move connection",
 
 	// Error messages
 	"msg_config_dir_error":  "Error creating config directory: %v\n",
@@ -53,7 +326,7 @@ var EN = map[string]string{
 	"msg_parse_error":       "Error parsing file: %v\n",
 	"msg_config_open_error": "Error opening config: %v\n",
 	"msg_app_error":         "Application error: %v\n",
-	
+
 	// Language code
 	"language_code": "en",
 }
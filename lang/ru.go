@@ -5,22 +5,79 @@ var RU = map[string]string{
 	"menu_title":        "Меню",
 	"connections_title": "Соединения",
 	"menu_add":          "Добавить соединение",
+	"menu_tunnels":      "Туннели",
+	"menu_recordings":   "Записи сеансов",
+	"menu_keys":         "SSH-ключи",
+	"menu_trash":        "Корзина (%d)",
 	"menu_language":     "Язык",
 	"menu_edit_config":  "Редактировать конфиг",
 	"menu_exit":         "Выход",
 
 	// Buttons
-	"btn_ok":     "OK",
-	"btn_cancel": "Отмена",
-	"btn_save":   "Сохранить",
+	"btn_ok":               "OK",
+	"btn_cancel":           "Отмена",
+	"btn_save":             "Сохранить",
+	"btn_copy":             "Копировать",
+	"btn_run":              "Выполнить",
+	"btn_new_window":       "Новое окно",
+	"btn_split_pane":       "Разделить панель",
+	"btn_clear_credential": "Удалить пароль",
+	"btn_terminal":         "Терминал",
+	"btn_unlock":           "Разблокировать",
+	"btn_generate":         "Создать",
+	"btn_deploy":           "Установить",
+	"btn_preview":          "Просмотр",
+	"btn_apply":            "Применить",
+	"btn_back":             "Назад",
+	"btn_agent_add":        "Добавить в агент",
+	"btn_connect":          "Подключиться",
 
 	// Forms
-	"form_server":   "SSH сервер",
-	"form_port":     "Порт",
-	"form_comment":  "Комментарий",
-	"form_username": "Имя пользователя",
-	"title_add":     "Добавить соединение",
-	"title_edit":    "Редактировать соединение",
+	"form_server":            "SSH сервер",
+	"form_port":              "Порт",
+	"form_comment":           "Комментарий",
+	"form_username":          "Имя пользователя",
+	"form_identity":          "Файл ключа",
+	"form_jump_host":         "Jump-хост",
+	"form_credential":        "Хранилище пароля",
+	"form_secret":            "Пароль/парольная фраза",
+	"credential_none":        "нет",
+	"credential_keyring":     "системная связка ключей",
+	"form_client":            "Клиент",
+	"form_transport":         "Транспорт",
+	"form_transport_options": "Параметры транспорта",
+	"transport_custom":       "своя команда",
+	"form_terminal":          "Команда терминала",
+	"form_group":             "Группа",
+	"form_template":          "Шаблон",
+	"template_none":          "(нет)",
+	"form_record":            "Записывать сеансы",
+	"form_reconnect":         "Переподключаться при сбоях сети",
+	"form_remote_command":    "Удаленная команда",
+	"form_request_tty":       "Запрос TTY",
+	"tty_default":            "по умолчанию",
+	"form_set_env":           "SetEnv ИМЯ=значение",
+	"form_send_env":          "Шаблоны SendEnv",
+	"client_exec":            "Системный ssh",
+	"client_native":          "Встроенный",
+	"title_add":              "Добавить соединение",
+	"title_clone":            "Копия соединения %s",
+	"title_edit":             "Редактировать соединение",
+	"form_name":              "Имя",
+	"title_rename":           "Переименовать %s",
+	"title_unlock":           "Разблокировка конфигурации",
+	"title_generate_key":     "Создание ключа",
+	"title_deploy_key":       "Установка открытого ключа на %d хост(ов)",
+	"title_bulk_edit":        "Массовое изменение %d соединений",
+	"title_bulk_preview":     "Изменений к применению: %d",
+	"form_public_key":        "Открытый ключ",
+	"form_set_identity":      "Использовать как файл ключа",
+	"form_key_type":          "Тип",
+	"form_key_bits":          "Размер RSA",
+	"form_key_file":          "Файл",
+	"form_key_passphrase":    "Пароль",
+	"form_key_confirm":       "Повторите пароль",
+	"form_config_passphrase": "Пароль",
 
 	// Messages
 	"msg_no_connections": "Нет сохраненных соединений",
@@ -30,20 +87,236 @@ var RU = map[string]string{
 	"msg_connecting":     "Подключение к %s\n",
 	"msg_conn_error":     "Ошибка подключения к %s: %v\n",
 
+	// Built-in client prompts
+	"prompt_passphrase":         "Введите пароль для ключа %s: ",
+	"prompt_host_key":           "Подлинность хоста %s не может быть установлена.\nОтпечаток ключа %s: %s.\nПродолжить подключение (yes/no)? ",
+	"msg_host_key_rejected":     "проверка ключа хоста не пройдена",
+	"prompt_config_passphrase":  "Пароль конфигурации: ",
+	"prompt_new_passphrase":     "Новый пароль конфигурации: ",
+	"prompt_confirm_passphrase": "Повторите пароль: ",
+
+	// SFTP browser
+	"sftp_local":     "Локально",
+	"sftp_remote":    "Сервер",
+	"sftp_help":      " Tab - Панель  Enter - Открыть  Backspace - Вверх  F5 - Копировать  F6 - Переименовать  F8 - Удалить  Esc - Закрыть",
+	"msg_sftp_error": "Ошибка SFTP для %s: %v",
+	"msg_sftp_dir":   "Копировать можно только файлы",
+	"msg_sftp_done":  "Скопирован %s",
+
+	// File copy
+	"title_copy":          "Копирование файлов: %s",
+	"form_copy_tool":      "Программа",
+	"form_copy_direction": "Направление",
+	"form_copy_local":     "Локальный путь",
+	"form_copy_remote":    "Удаленный путь",
+	"form_copy_recursive": "Рекурсивно",
+	"copy_upload":         "Загрузить (локально → сервер)",
+	"copy_download":       "Скачать (сервер → локально)",
+	"msg_enter_paths":     "Введите локальный и удаленный пути",
+	"msg_not_found":       "%s не найден в PATH",
+	"msg_copy_error":      "Ошибка копирования для %s: %v\n",
+	"msg_copy_done":       "Копирование завершено",
+	"prompt_continue":     "Нажмите Enter для продолжения...",
+
+	// History
+	"title_history":        "История: %s",
+	"msg_no_history":       " История пуста",
+	"msg_history_error":    "Ошибка записи истории: %v\n",
+	"msg_audit_error":      "Ошибка записи журнала аудита: %v\n",
+	"msg_journal_error":    "Ошибка журнала отмены: %v\n",
+	"op_add":               "добавление %s",
+	"op_edit":              "изменение %s",
+	"op_delete":            "удаление %s",
+	"op_move":              "перемещение %s",
+	"op_set_identity":      "файл ключа у %d соединений",
+	"status_undone":        "Отменено: %s (Ctrl+Y - Повторить)",
+	"status_redone":        "Повторено: %s",
+	"status_nothing_undo":  "Нечего отменять",
+	"status_nothing_redo":  "Нечего повторять",
+	"status_journal_stale": "Соединения изменены вне sshman, история отмены очищена",
+	"msg_audit_since":      "Неверное значение -since %q, ожидается длительность вроде 24h или дата вроде 2006-01-02",
+	"msg_audit_intact":     "Журнал аудита не поврежден: записей %d, последний хеш %s",
+	"msg_audit_broken":     "Журнал аудита поврежден: проблем %d в %d записях",
+	"audit_bad_line":       "строка %d: не запись аудита: %v",
+	"audit_bad_seq":        "строка %d: номер %d не по порядку",
+	"audit_bad_link":       "строка %d: предыдущий хеш не совпадает с предыдущей строкой",
+	"audit_bad_hash":       "строка %d: хеш не совпадает с записью, она изменена",
+
+	// Port forwards
+	"title_forwards":      "Проброс портов: %s",
+	"title_forward":       "Проброс порта",
+	"forwards_help":       " Enter - Изменить  Ctrl+N - Добавить  Del - Удалить  Esc - Назад",
+	"form_forward_type":   "Тип",
+	"form_forward_listen": "Слушать [адрес:]порт",
+	"form_forward_target": "Цель хост:порт",
+	"forward_local":       "Локальный (-L)",
+	"forward_remote":      "Удаленный (-R)",
+	"forward_dynamic":     "Динамический SOCKS (-D)",
+	"msg_no_forwards":     " Нет пробросов портов",
+	"msg_forward_listen":  "Укажите порт или адрес:порт",
+	"msg_forward_target":  "Цель должна быть в виде хост:порт",
+
+	// Tunnels
+	"title_tunnels":          "Туннели",
+	"tunnels_help":           " Enter/Del - Остановить туннель  Esc - Назад",
+	"tunnels_server":         "Сервер",
+	"tunnels_forwards":       "Пробросы",
+	"tunnels_status":         "Статус",
+	"tunnels_running":        "работает",
+	"tunnels_exited":         "завершен",
+	"msg_no_forwards_for":    "Для %s не настроен проброс портов",
+	"msg_tunnel_error":       "Ошибка туннеля для %s: %v",
+	"tunnels_type":           "Тип",
+	"tunnels_session":        "сеанс",
+	"tunnels_persistent":     "постоянный",
+	"msg_no_tunnels":         "Нет постоянных туннелей",
+	"msg_tunnel_exists":      "Постоянный туннель для %s уже запущен",
+	"msg_tunnel_not_found":   "Нет постоянного туннеля для %s",
+	"msg_tunnel_not_started": "Супервизор туннеля %s не запустился, см. %s",
+	"msg_tunnel_retry":       "Туннель к %s закрыт (%s), переподключение через %s\n",
+	"msg_port_busy":          "локальный порт %s уже занят",
+	"msg_keepalive_timeout":  "нет ответа на keepalive",
+	"msg_conn_not_found":     "Соединение %s не найдено",
+
+	// Run command
+	"title_run_command": "Выполнить команду на %d хост(ах)",
+	"title_hosts":       "Хосты",
+	"form_hosts":        "Хосты",
+	"form_command":      "Команда",
+	"form_concurrency":  "Параллельных сеансов",
+	"msg_enter_command": "Введите команду",
+	"run_summary":       " [green]%d успешно[-]  [red]%d с ошибкой[-]  [yellow]%d выполняется[-]  %d ожидает    Tab - Вывод  Esc - Закрыть",
+
+	// tmux and screen
+	"tmux_cluster_window":       "cluster",
+	"msg_no_multiplexer":        "sshman запущен не в tmux или screen",
+	"msg_tmux_only":             "панели поддерживаются только в tmux",
+	"msg_tmux_output":           "неожиданный вывод tmux",
+	"msg_no_terminal":           "команда запуска терминала не настроена",
+	"title_recordings":          "Записи сеансов",
+	"recordings_help":           " Enter - Воспроизвести (Пробел - пауза, q - стоп)  Del - Удалить  Esc - Назад",
+	"msg_no_recordings":         "Нет записей",
+	"title_trash":               "Корзина",
+	"trash_help":                " Enter - Восстановить  Del - Удалить навсегда  Esc - Назад",
+	"msg_trash_empty":           "Корзина пуста",
+	"trash_purge_in":            "удаление через %d дн.",
+	"msg_restore_exists":        "Соединение %s уже существует, сначала переименуйте его",
+	"op_restore":                "восстановление %s",
+	"op_bulk_edit":              "массовое изменение %d соединений",
+	"bulk_mixed":                "(разные значения)",
+	"bulk_mixed_clear":          "(разные значения, Ctrl+D очищает)",
+	"bulk_cleared":              "(очищено у всех)",
+	"bulk_keep":                 "Оставить: %s",
+	"bulk_empty":                "(пусто)",
+	"bulk_on":                   "Вкл",
+	"bulk_off":                  "Выкл",
+	"msg_bulk_nothing":          "Ни одно поле не изменено",
+	"msg_record_error":          "Ошибка записи сеанса: %v\n",
+	"msg_record_unsupported":    "Запись системного клиента ssh не поддерживается в Windows, используйте встроенный клиент\n",
+	"msg_cast_version":          "это не запись asciicast v2",
+	"msg_replay_done":           "Конец записи, нажмите любую клавишу",
+	"msg_hook_failed":           "хук %s %q завершился с ошибкой: %w",
+	"msg_env_format":            "SetEnv: пары ИМЯ=значение через пробел",
+	"msg_transport_fallback":    "%s не найден в PATH, подключение через ssh\n",
+	"msg_reconnect_countdown":   "Соединение с %s потеряно, переподключение через %s (попытка %d из %d), нажмите любую клавишу для отмены",
+	"history_reconnect":         "переподключение %d",
+	"msg_credential_store":      "неизвестное хранилище паролей %q",
+	"msg_credential_missing":    "сохраненный пароль не найден",
+	"msg_enter_secret":          "Введите пароль или парольную фразу для сохранения",
+	"msg_credential_error":      "Ошибка хранилища паролей: %v",
+	"msg_credential_cleared":    "Сохраненный пароль удален",
+	"title_details":             "Подключение %s",
+	"details_yes":               "да",
+	"details_forwards":          "Перенаправления портов",
+	"details_pre_hooks":         "Хуки до подключения",
+	"details_post_hooks":        "Хуки после подключения",
+	"details_renew_certificate": "Обновление сертификата",
+	"details_certificate":       "Сертификат",
+	"details_command":           "Команда",
+	"msg_wrong_passphrase":      "неверный пароль или поврежденный файл",
+	"msg_config_locked":         "конфигурация зашифрована, запустите из терминала или задайте SSHMAN_CONFIG_PASSPHRASE",
+	"msg_sealed_line":           "строка зашифрована ключом зашифрованной конфигурации, которая не разблокирована",
+	"msg_inherit_unsupported":   "фоновые процессы с зашифрованной конфигурацией не поддерживаются в Windows",
+	"msg_config_kdf":            "неподдерживаемая функция формирования ключа %q",
+	"msg_config_invalid":        "%s не является корректным JSON",
+	"msg_config_encrypted":      "конфигурация уже зашифрована",
+	"msg_config_not_encrypted":  "конфигурация не зашифрована",
+	"msg_config_encrypted_edit": "Конфигурация зашифрована, выполните \"sshman config decrypt\", чтобы редактировать ее вручную\n",
+	"msg_passphrase_terminal":   "для ввода пароля нужен терминал",
+	"msg_empty_passphrase":      "пароль не может быть пустым",
+	"msg_passphrase_mismatch":   "пароли не совпадают",
+	"title_keys":                "SSH-ключи в %s",
+	"keys_help":                 " n - Создать ключ  Esc - Назад",
+	"msg_no_keys":               "Ключи не найдены",
+	"msg_key_error":             "Ошибка ключа: %v",
+	"msg_key_bits":              "ключам RSA нужно не меньше %d бит",
+	"msg_key_type":              "неизвестный тип ключа %q",
+	"msg_enter_key_file":        "Введите имя файла ключа",
+	"msg_key_exists":            "%s уже существует",
+	"msg_not_certificate":       "%s не является SSH-сертификатом",
+	"cert_expired":              "сертификат истек",
+	"cert_not_yet_valid":        "сертификат еще не действует",
+	"cert_expiring":             "сертификат истекает через %s",
+	"cert_forever":              "бессрочно",
+	"cert_details":              "принципалы %s, действует %s - %s",
+	"msg_deploying":             "Установка %s на %s\n",
+	"msg_deploy_error":          "Не удалось установить ключ на %s: %v\n",
+	"msg_deploy_done":           "Ключ установлен на %d из %d хост(ов)\n",
+	"msg_agent_add_error":       "ошибка ssh-add: %v\n",
+	"agent_no_socket":           "ssh-agent: SSH_AUTH_SOCK не задан",
+	"agent_unavailable":         "ssh-agent недоступен: %v",
+	"agent_keys":                "ssh-agent: ключей %d",
+	"agent_more":                "... и еще %d",
+	"agent_key_loaded":          "в агенте",
+	"agent_key_not_loaded":      "нет в агенте",
+	"agent_key_missing":         "файл ключа не найден",
+	"agent_no_lifetime":         "время работы агента",
+	"key_weak_dsa":              "Слабый: ключи DSA небезопасны и отключены в современных версиях OpenSSH",
+	"key_weak_rsa":              "Слабый: ключ RSA на %d бит, используйте не меньше %d бит",
+	"key_flag_weak":             "слабый",
+	"key_flag_unused":           "не используется",
+	"key_flag_no_private":       "нет закрытого ключа",
+	"key_fingerprint":           "Отпечаток",
+	"key_file":                  "Файл",
+	"key_used_by":               "Используется",
+	"key_default_for":           "%s (ключ по умолчанию)",
+	"key_no_users":              "ни одним соединением",
+
+	// Command line
+	"cli_usage":          "Использование:\n  sshman                          запустить интерактивный интерфейс\n  sshman connect <сервер>         подключиться к сохраненному соединению\n  sshman tunnels list             список постоянных туннелей\n  sshman tunnels start <сервер>   запустить постоянный туннель с пробросами соединения\n  sshman tunnels stop <сервер>    остановить постоянный туннель\n  sshman config encrypt           зашифровать конфигурацию паролем\n  sshman config decrypt           снова хранить конфигурацию открытым текстом\n  sshman config read <файл>       показать файл истории, журнала или записи зашифрованной конфигурации\n  sshman audit [-server S] [-action A] [-since 24h|ДАТА] [-json]\n                                  показать журнал аудита\n  sshman audit verify             проверить цепочку хешей журнала аудита\n",
+	"cli_tunnels_header": "СЕРВЕР\tPID\tСТАТУС\tВРЕМЯ\tПОПЫТКИ\tПРОБРОСЫ\tПОСЛЕДНЯЯ ОШИБКА",
+	"cli_audit_header":   "ВРЕМЯ\tПОЛЬЗОВАТЕЛЬ\tДЕЙСТВИЕ\tСЕРВЕР\tКОД\tИЗМЕНЕНИЯ",
+
 	// Dialog messages
-	"dlg_connect": "Подключиться к %s?",
-	"dlg_edit":    "Редактировать соединение %s?",
-	"dlg_delete":  "Удалить соединение %s?",
-	"dlg_add":     "Добавить новое соединение?",
+	"dlg_connect":     "Подключиться к %s?",
+	"dlg_edit":        "Редактировать соединение %s?",
+	"dlg_delete":      "Переместить соединение %s в корзину?",
+	"dlg_purge":       "Удалить %s из корзины навсегда? Сохраненный пароль тоже будет удален.",
+	"dlg_add":         "Добавить новое соединение?",
+	"dlg_delete_file": "Удалить %s?",
+	"dlg_agent_add":   "Ключ %s не загружен в ssh-agent. Добавить его на %s перед подключением?",
 
 	// Context menu
-	"ctx_connect": "Подключить",
-	"ctx_edit":    "Редактировать",
-	"ctx_cancel":  "Отмена",
-	"ctx_actions": "Действия для %s",
+	"ctx_connect":           "Подключить",
+	"ctx_edit":              "Редактировать",
+	"ctx_clone":             "Клонировать",
+	"ctx_bulk_edit":         "Массовое изменение (выбранные хосты)",
+	"ctx_details":           "Подробности",
+	"ctx_connect_forwards":  "Подключиться с пробросом портов",
+	"ctx_start_tunnel":      "Запустить туннель",
+	"ctx_persistent_tunnel": "Запустить постоянный туннель",
+	"ctx_forwards":          "Проброс портов",
+	"ctx_sftp":              "SFTP браузер",
+	"ctx_copy":              "Копировать файлы",
+	"ctx_history":           "История",
+	"ctx_run_command":       "Выполнить команду (выбранные хосты)",
+	"ctx_deploy_key":        "Установить открытый ключ (выбранные хосты)",
+	"ctx_sync_panes":        "Открыть выбранные в синхронных панелях tmux",
+	"ctx_cancel":            "Отмена",
+	"ctx_actions":           "Действия для %s",
 
 	// Help text
-	"help_text": " Управление:                           \n ↑↓ - Навигация по списку              Tab - Переключить раздел\n Enter - Подключиться                  Ctrl+E - Редактировать соединение\n Ctrl+N - Добавить соединение          Del - Удалить соединение\n Ctrl+R - Обновить окно                Ctrl+C - Выход",
+	"help_text": " Управление:                           \n ↑↓ - Навигация по списку              Tab - Переключить раздел\n Enter - Подключиться                  Ctrl+E - Редактировать соединение\n Ctrl+N - Добавить соединение          Del - Удалить соединение\n Ctrl+R - Обновить окно                Ctrl+C - Выход\n Ctrl+O - Действия с соединением      Пробел - Выбрать (+ все, - снять)\n Ctrl+Z - Отменить  Ctrl+Y - Повторить  Shift+↑↓ - Переместить соединение",
 
 	// Error messages
 	"msg_config_dir_error":  "Ошибка создания директории конфигурации: %v\n",
@@ -53,7 +326,7 @@ var RU = map[string]string{
 	"msg_parse_error":       "Ошибка разбора файла: %v\n",
 	"msg_config_open_error": "Ошибка открытия конфига: %v\n",
 	"msg_app_error":         "Ошибка запуска приложения: %v\n",
-	
+
 	// Language code
 	"language_code": "ru",
 }
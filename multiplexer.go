@@ -0,0 +1,123 @@
+/*
+* tmux and screen integration
+ */
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Terminal multiplexers sshman can open sessions in
+const (
+	multiplexerTmux   = "tmux"
+	multiplexerScreen = "screen"
+)
+
+// detectMultiplexer returns the multiplexer sshman is running inside, empty if none
+func detectMultiplexer() string {
+	if os.Getenv("TMUX") != "" {
+		return multiplexerTmux
+	}
+	if os.Getenv("STY") != "" {
+		return multiplexerScreen
+	}
+	return ""
+}
+
+// sessionCommand returns the command opening the connection in a separate terminal:
+// sshman itself in connect mode, so the client backend and history work the same way
+func sessionCommand(conn SSHConnection) ([]string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return []string{executable, "connect", conn.Server}, nil
+}
+
+// shellCommand joins the command into a single string for tools that run it through a shell
+func shellCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// openInWindow opens the connection in a new tmux or screen window named after the host
+func openInWindow(conn SSHConnection) error {
+	command, err := sessionCommand(conn)
+	if err != nil {
+		return err
+	}
+
+	switch detectMultiplexer() {
+	case multiplexerTmux:
+		return exec.Command("tmux", "new-window", "-n", conn.Server, shellCommand(command)).Run()
+	case multiplexerScreen:
+		args := append([]string{"-X", "screen", "-t", conn.Server}, command...)
+		return exec.Command("screen", args...).Run()
+	}
+	return errors.New(currentLang["msg_no_multiplexer"])
+}
+
+// openInPane opens the connection in a new tmux pane next to sshman, titled with the host
+func openInPane(conn SSHConnection) error {
+	if detectMultiplexer() != multiplexerTmux {
+		return errors.New(currentLang["msg_tmux_only"])
+	}
+	command, err := sessionCommand(conn)
+	if err != nil {
+		return err
+	}
+
+	pane, err := exec.Command("tmux", "split-window", "-h", "-P", "-F", "#{pane_id}", shellCommand(command)).Output()
+	if err != nil {
+		return err
+	}
+	return exec.Command("tmux", "select-pane", "-t", strings.TrimSpace(string(pane)), "-T", conn.Server).Run()
+}
+
+// openSynchronizedPanes opens all connections in one new tmux window with tiled panes
+// and synchronized input, so keystrokes go to every host
+func openSynchronizedPanes(connections []SSHConnection) error {
+	if detectMultiplexer() != multiplexerTmux {
+		return errors.New(currentLang["msg_tmux_only"])
+	}
+	if len(connections) == 0 {
+		return nil
+	}
+
+	var window string
+	for i, conn := range connections {
+		command, err := sessionCommand(conn)
+		if err != nil {
+			return err
+		}
+
+		var output []byte
+		if i == 0 {
+			output, err = exec.Command("tmux", "new-window", "-P", "-F", "#{window_id} #{pane_id}",
+				"-n", currentLang["tmux_cluster_window"], shellCommand(command)).Output()
+		} else {
+			output, err = exec.Command("tmux", "split-window", "-t", window, "-P", "-F", "#{window_id} #{pane_id}",
+				shellCommand(command)).Output()
+		}
+		if err != nil {
+			return err
+		}
+
+		ids := strings.Fields(string(output))
+		if len(ids) != 2 {
+			return errors.New(currentLang["msg_tmux_output"])
+		}
+		window = ids[0]
+		_ = exec.Command("tmux", "select-pane", "-t", ids[1], "-T", conn.Server).Run()
+		// Re-tile after each split so there is room for the next pane
+		_ = exec.Command("tmux", "select-layout", "-t", window, "tiled").Run()
+	}
+
+	return exec.Command("tmux", "set-window-option", "-t", window, "synchronize-panes", "on").Run()
+}
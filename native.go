@@ -0,0 +1,380 @@
+/*
+* Built-in SSH client
+ */
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+// Default private keys tried when the connection has no identity file
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// connectionUser returns the login name for the connection
+// Falls back to the user part of the server address and then to the local user
+func connectionUser(conn SSHConnection) string {
+	if conn.Username != "" {
+		return conn.Username
+	}
+	if at := strings.LastIndex(conn.Server, "@"); at > 0 {
+		return conn.Server[:at]
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// nativeConnect opens an interactive session using the built-in SSH client
+// The local terminal is switched to raw mode and proxied to the remote shell or command,
+// whose output goes to output; typed input is also copied to input unless it is nil
+func nativeConnect(conn SSHConnection, output, input io.Writer) error {
+	client, err := dialNative(conn, true)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stopForwards, err := startNativeForwards(client, conn.Forwards)
+	if err != nil {
+		return err
+	}
+	defer stopForwards()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	nativeSessionEnv(conn, session)
+
+	fd := int(os.Stdin.Fd())
+	if nativeWantsTTY(conn, fd) {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(termType, height, width, modes); err != nil {
+			return err
+		}
+
+		stopResize := watchWindowSize(fd, func(width, height int) {
+			_ = session.WindowChange(height, width)
+		})
+		defer stopResize()
+
+		// Without a remote PTY the local terminal keeps echo and line editing, like ssh -T
+		if term.IsTerminal(fd) {
+			state, err := term.MakeRaw(fd)
+			if err != nil {
+				return err
+			}
+			defer term.Restore(fd, state)
+		}
+	}
+
+	stdin, releaseStdin, err := cancelableStdin()
+	if err != nil {
+		return err
+	}
+	defer releaseStdin()
+
+	remoteStdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	session.Stdout = output
+	session.Stderr = output
+
+	if conn.RemoteCommand != "" {
+		err = session.Start(conn.RemoteCommand)
+	} else {
+		err = session.Shell()
+	}
+	if err != nil {
+		return err
+	}
+	var typed io.Reader = stdin
+	if input != nil {
+		typed = io.TeeReader(stdin, input)
+	}
+	go func() {
+		_, _ = io.Copy(remoteStdin, typed)
+	}()
+
+	return session.Wait()
+}
+
+// dialNative connects and authenticates to the server of the connection
+// Without interactive the terminal is never prompted: encrypted keys, unknown host keys
+// and keyboard-interactive challenges fail instead
+func dialNative(conn SSHConnection, interactive bool) (*ssh.Client, error) {
+	address := connectionAddress(conn)
+	if address == "" {
+		return nil, errors.New(currentLang["msg_enter_server"])
+	}
+
+	hostKeyCallback, hostKeyAlgorithms, err := knownHostsCallback(address, interactive)
+	if err != nil {
+		return nil, err
+	}
+
+	methods, agentConn := nativeAuthMethods(conn, interactive)
+	// The agent only signs during the handshake, which ends before dialNative returns
+	if agentConn != nil {
+		defer agentConn.Close()
+	}
+	clientConfig := &ssh.ClientConfig{
+		User:              connectionUser(conn),
+		Auth:              methods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           hostTimeout * 5,
+	}
+	if conn.JumpHost == "" {
+		return ssh.Dial("tcp", address, clientConfig)
+	}
+
+	// Connect through the jump host, authenticating with the same keys
+	jump, err := dialNative(jumpConnection(conn), interactive)
+	if err != nil {
+		return nil, err
+	}
+	tunnel, err := jump.Dial("tcp", address)
+	if err != nil {
+		jump.Close()
+		return nil, err
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(tunnel, address, clientConfig)
+	if err != nil {
+		tunnel.Close()
+		jump.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(clientConn, channels, requests)
+	go func() {
+		_ = client.Wait()
+		jump.Close()
+	}()
+	return client, nil
+}
+
+// jumpConnection returns the connection settings of the jump host in [user@]host[:port] form
+func jumpConnection(conn SSHConnection) SSHConnection {
+	jump := SSHConnection{Server: conn.JumpHost, IdentityFile: conn.IdentityFile}
+	if at := strings.LastIndex(jump.Server, "@"); at > 0 {
+		jump.Username = jump.Server[:at]
+		jump.Server = jump.Server[at+1:]
+	}
+	if host, port, err := net.SplitHostPort(jump.Server); err == nil {
+		jump.Server, jump.Port = host, port
+	}
+	return jump
+}
+
+// nativeAuthMethods returns authentication methods in the order ssh tries them:
+// agent, key files, then password and keyboard-interactive
+// A stored credential is used as key passphrase and password before prompting
+// The agent connection, if any, is returned for the caller to close after authenticating
+func nativeAuthMethods(conn SSHConnection, interactive bool) ([]ssh.AuthMethod, net.Conn) {
+	var methods []ssh.AuthMethod
+	var agentConn net.Conn
+	secret := connectionSecret(conn)
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if dialed, err := net.Dial("unix", socket); err == nil {
+			agentConn = dialed
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, path := range identityFiles(conn) {
+		if signer, err := loadSigner(path, secret, interactive); err == nil {
+			// Like ssh, the certificate next to the key is offered before the plain key
+			if withCert, ok := certSigner(path, signer); ok {
+				signers = append(signers, withCert)
+			}
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if secret != "" {
+		methods = append(methods, ssh.Password(secret))
+	}
+	if interactive {
+		methods = append(methods, ssh.KeyboardInteractive(keyboardInteractive))
+	}
+	return methods, agentConn
+}
+
+// identityFiles returns the key files tried for the connection: its identity file,
+// or the default keys in ~/.ssh without one
+func identityFiles(conn SSHConnection) []string {
+	if conn.IdentityFile != "" {
+		return []string{expandHome(conn.IdentityFile)}
+	}
+	var paths []string
+	for _, name := range defaultIdentityFiles {
+		paths = append(paths, filepath.Join(sshDir(), name))
+	}
+	return paths
+}
+
+// loadSigner reads a private key, decrypting it with the stored secret or asking for
+// the passphrase if the key is encrypted
+func loadSigner(path, secret string, interactive bool) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	if secret != "" {
+		if signer, err := ssh.ParsePrivateKeyWithPassphrase(data, []byte(secret)); err == nil || !interactive {
+			return signer, err
+		}
+	}
+	if !interactive {
+		return nil, err
+	}
+
+	passphrase, err := readSecret(fmt.Sprintf(currentLang["prompt_passphrase"], path))
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+}
+
+// keyboardInteractive answers server challenges by prompting on the terminal
+func keyboardInteractive(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	if name != "" {
+		fmt.Println(name)
+	}
+	if instruction != "" {
+		fmt.Println(instruction)
+	}
+
+	answers := make([]string, len(questions))
+	for i, question := range questions {
+		var err error
+		if echos[i] {
+			fmt.Print(question)
+			answers[i], err = bufio.NewReader(os.Stdin).ReadString('\n')
+			answers[i] = strings.TrimRight(answers[i], "\r\n")
+		} else {
+			answers[i], err = readSecret(question)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return answers, nil
+}
+
+// readSecret prompts for a value without echoing it to the terminal
+func readSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return string(secret), err
+}
+
+// knownHostsCallback verifies host keys against ~/.ssh/known_hosts
+// Unknown hosts are confirmed interactively and remembered, changed keys are rejected
+func knownHostsCallback(address string, interactive bool) (ssh.HostKeyCallback, []string, error) {
+	knownHostsPath := filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+	if err := os.MkdirAll(filepath.Dir(knownHostsPath), 0700); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(knownHostsPath, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	file.Close()
+
+	check, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 || !interactive {
+			return err
+		}
+
+		fmt.Printf(currentLang["prompt_host_key"], hostname, key.Type(), ssh.FingerprintSHA256(key))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+			return errors.New(currentLang["msg_host_key_rejected"])
+		}
+
+		file, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}
+
+	return callback, knownHostKeyAlgorithms(check, address), nil
+}
+
+// knownHostKeyAlgorithms returns the key algorithms already known for the address,
+// so the server is asked for a key type that can actually be verified
+func knownHostKeyAlgorithms(check ssh.HostKeyCallback, address string) []string {
+	// A zero key never matches, so the check reports every key known for the host
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	err = check(address, &net.TCPAddr{}, probe)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		switch known.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, known.Key.Type())
+		}
+	}
+	return algorithms
+}
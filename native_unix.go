@@ -0,0 +1,60 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// nativeStdin is a non-blocking view of the terminal input shared by native sessions.
+// It is kept for the lifetime of the process so its finalizer never closes stdin.
+var nativeStdin *os.File
+
+// cancelableStdin returns terminal input whose pending reads are interrupted by release,
+// so the copy goroutine does not swallow keystrokes meant for the TUI after the session ends
+func cancelableStdin() (*os.File, func(), error) {
+	fd := int(os.Stdin.Fd())
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return nil, nil, err
+	}
+	if nativeStdin == nil {
+		nativeStdin = os.NewFile(uintptr(fd), "stdin")
+	}
+	_ = nativeStdin.SetReadDeadline(time.Time{})
+
+	release := func() {
+		_ = nativeStdin.SetReadDeadline(time.Now())
+		_ = syscall.SetNonblock(fd, false)
+	}
+	return nativeStdin, release, nil
+}
+
+// watchWindowSize calls resize with the new size whenever the local terminal is resized, until stopped
+func watchWindowSize(fd int, resize func(width, height int)) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if width, height, err := term.GetSize(fd); err == nil {
+					resize(width, height)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
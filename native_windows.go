@@ -0,0 +1,42 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// cancelableStdin returns the terminal input; console reads cannot be interrupted on Windows
+func cancelableStdin() (*os.File, func(), error) {
+	return os.Stdin, func() {}, nil
+}
+
+// watchWindowSize polls the console size and calls resize when it changes, until stopped
+func watchWindowSize(fd int, resize func(width, height int)) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(500 * time.Millisecond)
+
+	go func() {
+		lastWidth, lastHeight, _ := term.GetSize(fd)
+		for {
+			select {
+			case <-ticker.C:
+				width, height, err := term.GetSize(fd)
+				if err == nil && (width != lastWidth || height != lastHeight) {
+					lastWidth, lastHeight = width, height
+					resize(width, height)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+* Automatic reconnect of dropped sessions
+ */
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// Defaults of the reconnect policy
+const (
+	reconnectAttempts   = 5
+	reconnectMaxDelay   = 30 * time.Second
+	reconnectFirstDelay = 2 * time.Second
+	reconnectStableTime = time.Minute // a session up this long starts over with the first attempt
+)
+
+// ReconnectPolicy enables reconnecting a session that ended with a network failure
+type ReconnectPolicy struct {
+	MaxAttempts int `json:"max_attempts,omitempty"` // attempts in a row, default 5
+	MaxDelay    int `json:"max_delay,omitempty"`    // longest delay between attempts in seconds, default 30
+}
+
+// attempts returns the number of attempts in a row allowed by the policy
+func (p *ReconnectPolicy) attempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return reconnectAttempts
+}
+
+// delay returns the backoff before the attempt, doubling from the first delay up to the maximum
+func (p *ReconnectPolicy) delay(attempt int) time.Duration {
+	maxDelay := reconnectMaxDelay
+	if p.MaxDelay > 0 {
+		maxDelay = time.Duration(p.MaxDelay) * time.Second
+	}
+	delay := reconnectFirstDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// networkFailure reports whether the session ended because the connection failed or dropped,
+// as opposed to a logout or a remote command exiting
+// The system ssh client exits with 255 on its own errors; the built-in client reports
+// sessions closed without an exit status and network errors
+func networkFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode() == 255
+	}
+	var missingErr *ssh.ExitMissingError
+	if errors.As(err, &missingErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// reconnectCountdown shows the time left before the next attempt and reports whether
+// to reconnect; any key cancels
+func reconnectCountdown(server string, delay time.Duration, attempt, attempts int) bool {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		time.Sleep(delay)
+		return true
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		time.Sleep(delay)
+		return true
+	}
+	defer term.Restore(fd, state)
+
+	stdin, releaseStdin, err := cancelableStdin()
+	if err != nil {
+		time.Sleep(delay)
+		return true
+	}
+	defer releaseStdin()
+	pressed := make(chan struct{})
+	go func() {
+		if _, err := stdin.Read(make([]byte, 1)); err == nil {
+			close(pressed)
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	deadline := time.Now().Add(delay)
+	for {
+		left := time.Until(deadline).Round(time.Second)
+		fmt.Printf("\r\x1b[K"+currentLang["msg_reconnect_countdown"], server, left, attempt, attempts)
+		if left <= 0 {
+			fmt.Print("\r\n")
+			return true
+		}
+		select {
+		case <-pressed:
+			fmt.Print("\r\n")
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// execRecorded runs the system ssh client or the transport program in a PTY and copies its output to the terminal
+// and the recorder, and the typed input to the program and the recorder
+func execRecorded(connection SSHConnection, rec *recorder) error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	stdin, releaseStdin, err := cancelableStdin()
+	if err != nil {
+		return err
+	}
+	defer releaseStdin()
+
+	args := transportCommand(connection)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = credentialEnv(connection)
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	_ = pty.InheritSize(os.Stdin, ptmx)
+	stopResize := watchWindowSize(fd, func(width, height int) {
+		_ = pty.Setsize(ptmx, &pty.Winsize{Rows: uint16(height), Cols: uint16(width)})
+	})
+	defer stopResize()
+
+	go func() {
+		_, _ = io.Copy(ptmx, io.TeeReader(stdin, &rec.input))
+	}()
+	copied := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.MultiWriter(os.Stdout, &rec.output), ptmx)
+		close(copied)
+	}()
+
+	err = cmd.Wait()
+	// The output ends when the PTY closes; processes left holding it must not block the return
+	select {
+	case <-copied:
+	case <-time.After(time.Second):
+	}
+	return err
+}
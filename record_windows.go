@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "log"
+
+// execRecorded runs the system ssh client unrecorded: its console output cannot be captured
+// on Windows, use the built-in client to record sessions
+func execRecorded(connection SSHConnection, rec *recorder) error {
+	log.Print(currentLang["msg_record_unsupported"])
+	return execConnect(connection)
+}
@@ -0,0 +1,464 @@
+/*
+* Session recording in asciicast v2 format
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"golang.org/x/term"
+)
+
+// Longest pause kept when replaying a recording
+const replayMaxIdle = 2 * time.Second
+
+var recordingsDir = filepath.Join(configDir, "recordings")
+
+// castHeader is the first line of an asciicast v2 file
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recorder writes a session to a recording as timed output and input events
+// The file is created with the first event, so sessions that never start leave none
+type recorder struct {
+	mutex   sync.Mutex
+	path    string
+	header  castHeader
+	file    *os.File
+	started time.Time
+	output  castStream
+	input   castStream
+	typed   bool  // whether any input was recorded
+	closed  bool  // set by Close, later writes are dropped
+	err     error // first write error, reported by Close
+}
+
+// castStream is one direction of a recorded session, written as events of its kind
+type castStream struct {
+	rec     *recorder
+	kind    string // "o" for output, "i" for input
+	pending []byte // incomplete UTF-8 sequence held until the next write
+}
+
+// recordingEnabled reports whether sessions of the connection or its group are recorded
+func recordingEnabled(conn SSHConnection) bool {
+	if conn.Record {
+		return true
+	}
+	group, ok := findGroup(conn.Group)
+	return ok && group.Record
+}
+
+// newRecorder prepares a recording of a session of the connection with the terminal size
+func newRecorder(conn SSHConnection, width, height int) (*recorder, error) {
+	if err := os.MkdirAll(recordingsDir, 0700); err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	name := unsafeFileChars.ReplaceAllString(privateName(conn.Server), "_") + "-" + started.Format("20060102-150405.000") + ".cast"
+	rec := &recorder{
+		path: filepath.Join(recordingsDir, name),
+		header: castHeader{
+			Version:   2,
+			Width:     width,
+			Height:    height,
+			Timestamp: started.Unix(),
+			Title:     conn.Server,
+			Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+		},
+		started: started,
+	}
+	rec.output = castStream{rec: rec, kind: "o"}
+	rec.input = castStream{rec: rec, kind: "i"}
+	return rec, nil
+}
+
+// Most numbered names tried when a recording of the same name exists
+const recordingNameTries = 100
+
+// create creates the recording file with its header, numbering the name when a
+// recording of the server started at the same time exists
+func (r *recorder) create() error {
+	header, err := json.Marshal(r.header)
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(r.path, ".cast")
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	for n := 1; os.IsExist(err) && n < recordingNameTries; n++ {
+		r.path = fmt.Sprintf("%s-%d.cast", base, n)
+		file, err = os.OpenFile(r.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(sealLine(header), '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	return nil
+}
+
+// Write appends the data as events of the stream
+// Errors are kept for Close, so a full disk never interrupts the session itself
+func (s *castStream) Write(data []byte) (int, error) {
+	s.rec.mutex.Lock()
+	defer s.rec.mutex.Unlock()
+	s.write(data)
+	return len(data), nil
+}
+
+// write appends the data up to the last complete rune, holding the rest; the caller holds the mutex
+func (s *castStream) write(data []byte) {
+	chunk := append(s.pending, data...)
+	// Events are JSON strings, so a rune split between writes waits for its remaining bytes
+	end := len(chunk)
+	for i := len(chunk) - 1; i >= 0 && i >= len(chunk)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(chunk[i]) {
+			if !utf8.FullRune(chunk[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	s.pending = append([]byte(nil), chunk[end:]...)
+	if end > 0 {
+		s.rec.writeEvent(s.kind, chunk[:end])
+	}
+}
+
+// writeEvent writes one event with the time since the start of the recording,
+// creating the file with the first one
+func (r *recorder) writeEvent(kind string, data []byte) {
+	if r.err != nil || r.closed {
+		return
+	}
+	if r.file == nil {
+		if r.err = r.create(); r.err != nil {
+			return
+		}
+	}
+	event, err := json.Marshal([]interface{}{time.Since(r.started).Seconds(), kind, string(data)})
+	if err == nil {
+		_, err = r.file.Write(append(sealLine(event), '\n'))
+	}
+	r.err = err
+	if kind == "i" {
+		r.typed = true
+	}
+}
+
+// Close writes any held bytes and closes the recording file
+func (r *recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return r.err
+	}
+	for _, stream := range []*castStream{&r.output, &r.input} {
+		if len(stream.pending) > 0 {
+			r.writeEvent(stream.kind, stream.pending)
+			stream.pending = nil
+		}
+	}
+	r.closed = true
+	if r.file == nil {
+		return r.err
+	}
+	if err := r.file.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// recordedPath returns the path of the recording, empty if no file was created
+func (r *recorder) recordedPath() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return ""
+	}
+	return r.path
+}
+
+// discard closes and removes the recording file
+func (r *recorder) discard() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	if r.file != nil {
+		_ = r.file.Close()
+		_ = os.Remove(r.path)
+		r.file = nil
+	}
+}
+
+// recordSession runs an interactive session of the connection while recording its output
+// and what was typed. Returns the path of the recording, empty if the session never started
+func recordSession(conn SSHConnection) (string, error) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+	rec, err := newRecorder(conn, width, height)
+	if err != nil {
+		return "", err
+	}
+
+	native := conn.Client == clientNative && conn.Transport == transportSSH
+	if native {
+		err = nativeConnect(conn, io.MultiWriter(os.Stdout, &rec.output), &rec.input)
+	} else {
+		err = execRecorded(conn, rec)
+	}
+	if closeErr := rec.Close(); closeErr != nil {
+		log.Printf(currentLang["msg_record_error"], closeErr)
+	}
+	// The built-in client writes nothing before the session opens, while ssh prints its
+	// connection errors; a failure before anything was typed is such an error
+	if !native && networkFailure(err) && !rec.typed {
+		rec.discard()
+	}
+	return rec.recordedPath(), err
+}
+
+// recordingInfo describes a recording file in the recordings view
+type recordingInfo struct {
+	path     string
+	server   string
+	started  time.Time
+	size     int64
+	modified time.Time
+}
+
+// loadRecordings returns the recordings, newest first
+func loadRecordings() ([]recordingInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(recordingsDir, "*.cast"))
+	if err != nil {
+		return nil, err
+	}
+
+	var recordings []recordingInfo
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		recording := recordingInfo{path: path, size: info.Size(), modified: info.ModTime()}
+		if header, err := readCastHeader(path); err == nil {
+			recording.server = header.Title
+			recording.started = time.Unix(header.Timestamp, 0)
+		}
+		recordings = append(recordings, recording)
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].started.After(recordings[j].started)
+	})
+	return recordings, nil
+}
+
+// readCastHeader reads the header line of a recording
+func readCastHeader(path string) (castHeader, error) {
+	var header castHeader
+	file, err := os.Open(path)
+	if err != nil {
+		return header, err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return header, err
+	}
+	if line, err = openLine(line); err != nil {
+		return header, err
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, err
+	}
+	if header.Version != 2 {
+		return header, errors.New(currentLang["msg_cast_version"])
+	}
+	return header, nil
+}
+
+// replayRecording plays the recording in the terminal with its original timing,
+// shortening long pauses; Space pauses and q or Esc stops the playback
+func replayRecording(path string) error {
+	if _, err := readCastHeader(path); err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	stdin, releaseStdin, err := cancelableStdin()
+	if err != nil {
+		return err
+	}
+	defer releaseStdin()
+	keys := make(chan byte, 16)
+	go func() {
+		defer close(keys)
+		buffer := make([]byte, 1)
+		for {
+			if _, err := stdin.Read(buffer); err != nil {
+				return
+			}
+			keys <- buffer[0]
+		}
+	}()
+	isStop := func(key byte, ok bool) bool {
+		return !ok || key == 'q' || key == 27 || key == 3
+	}
+
+	// wait sleeps for the delay and reports whether a key asked to stop
+	wait := func(delay time.Duration) bool {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				return false
+			case key, ok := <-keys:
+				if isStop(key, ok) {
+					return true
+				}
+				if key == ' ' {
+					if key, ok := <-keys; isStop(key, ok) {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	fmt.Print("\x1b[2J\x1b[H")
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Scan() // header
+	last := 0.0
+	for scanner.Scan() {
+		line, err := openLine(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		var event []interface{}
+		if json.Unmarshal(line, &event) != nil || len(event) != 3 {
+			continue
+		}
+		timestamp, _ := event[0].(float64)
+		kind, _ := event[1].(string)
+		data, _ := event[2].(string)
+		// Typed input is kept for reference, its echo is part of the output
+		if kind != "o" {
+			continue
+		}
+
+		delay := time.Duration((timestamp - last) * float64(time.Second))
+		if delay > replayMaxIdle {
+			delay = replayMaxIdle
+		}
+		last = timestamp
+		if delay > 0 && wait(delay) {
+			return nil
+		}
+		_, _ = os.Stdout.WriteString(data)
+	}
+
+	fmt.Print("\r\n" + currentLang["msg_replay_done"])
+	<-keys
+	return scanner.Err()
+}
+
+// showRecordings displays the recordings; Enter replays and Del deletes the selected recording
+func showRecordings(app *tview.Application, connectionsList *tview.List) {
+	recordings, err := loadRecordings()
+	if err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_record_error"], err))
+		return
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetTitle(currentLang["title_recordings"]).SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	list.SetBackgroundColor(tcell.ColorNavy)
+	list.SetMainTextColor(tcell.ColorWhite)
+	list.SetSelectedTextColor(tcell.ColorWhite)
+	list.SetSelectedBackgroundColor(tcell.ColorDarkRed)
+
+	if len(recordings) == 0 {
+		list.AddItem(currentLang["msg_no_recordings"], "", 0, nil)
+	}
+	for _, recording := range recordings {
+		path := recording.path
+		duration := recording.modified.Sub(recording.started).Round(time.Second)
+		line := fmt.Sprintf(" %s  %-30s %8s  %8s", recording.started.Format("2006-01-02 15:04"),
+			tview.Escape(recording.server), duration, formatSize(recording.size))
+		list.AddItem(line, "", 0, func() {
+			var err error
+			app.Suspend(func() {
+				err = replayRecording(path)
+			})
+			if err != nil {
+				showError(app, connectionsList, fmt.Sprintf(currentLang["msg_record_error"], err))
+			}
+		})
+	}
+
+	list.SetDoneFunc(func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	})
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		current := list.GetCurrentItem()
+		if event.Key() != tcell.KeyDelete || current >= len(recordings) {
+			return event
+		}
+		if err := os.Remove(recordings[current].path); err != nil {
+			showError(app, connectionsList, fmt.Sprintf(currentLang["msg_record_error"], err))
+			return nil
+		}
+		showRecordings(app, connectionsList)
+		return nil
+	})
+
+	hint := tview.NewTextView().SetText(currentLang["recordings_help"])
+	hint.SetBackgroundColor(tcell.ColorNavy)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(list, 0, 1, true).
+		AddItem(hint, 1, 0, false)
+	app.SetRoot(centerWidget(app, layout), true)
+}
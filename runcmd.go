@@ -0,0 +1,282 @@
+/*
+* Run a command on multiple hosts
+ */
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Default number of hosts a command runs on at the same time
+const defaultConcurrency = 5
+
+// hostRun is the output and state of a command on one host
+type hostRun struct {
+	conn     SSHConnection
+	mutex    sync.Mutex
+	output   strings.Builder
+	running  bool
+	finished bool
+	exitCode int
+}
+
+// Write appends command output, used as stdout and stderr of the command
+func (r *hostRun) Write(data []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.output.Write(data)
+}
+
+// state returns the output and status of the run
+func (r *hostRun) state() (string, bool, bool, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.output.String(), r.running, r.finished, r.exitCode
+}
+
+// runCommandForm displays a form for the command to run on the connections
+func runCommandForm(app *tview.Application, connectionsList *tview.List, connections []SSHConnection) {
+	if len(connections) == 0 {
+		return
+	}
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+	backToMain := func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	}
+
+	servers := make([]string, len(connections))
+	for i, conn := range connections {
+		servers[i] = conn.Server
+	}
+
+	form := newStyledForm()
+	form.
+		AddTextView(currentLang["form_hosts"], strings.Join(servers, ", "), 60, 2, true, false).
+		AddInputField(currentLang["form_command"], "", 60, nil, nil).
+		AddInputField(currentLang["form_concurrency"], strconv.Itoa(defaultConcurrency), 5, tview.InputFieldInteger, nil).
+		AddButton(currentLang["btn_run"], func() {
+			command := strings.TrimSpace(form.GetFormItem(1).(*tview.InputField).GetText())
+			concurrency, _ := strconv.Atoi(form.GetFormItem(2).(*tview.InputField).GetText())
+			if command == "" {
+				errorText.SetText(currentLang["msg_enter_command"])
+				return
+			}
+			if concurrency < 1 {
+				concurrency = 1
+			}
+			runOnHosts(app, connectionsList, connections, command, concurrency)
+		}).
+		AddButton(currentLang["btn_cancel"], backToMain)
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(errorText, 1, 0, false)
+
+	formFlex.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_run_command"], len(connections))).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerForm(app, formFlex, form), true)
+	app.SetFocus(form)
+}
+
+// runRemoteCommand runs the command on the host of the connection without a terminal,
+// writing stdout and stderr to output
+func runRemoteCommand(ctx context.Context, conn SSHConnection, command string, output io.Writer) error {
+	if conn.Client == clientNative {
+		client, err := dialNative(conn, false)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		session, err := client.NewSession()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+
+		session.Stdout = output
+		session.Stderr = output
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				client.Close()
+			case <-done:
+			}
+		}()
+		return session.Run(command)
+	}
+
+	// Batch mode would disable askpass, which answers for connections with a credential
+	args := sshArgs(conn)
+	if conn.Credential == "" {
+		args = append([]string{"-o", "BatchMode=yes"}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "ssh", append(args, "--", command)...)
+	cmd.Env = batchCredentialEnv(conn)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
+
+// runOnHosts runs the command on the connections in parallel and shows the results view
+// with a host list, the output of the selected host and a summary
+func runOnHosts(app *tview.Application, connectionsList *tview.List, connections []SSHConnection, command string, concurrency int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make([]*hostRun, len(connections))
+	for i, conn := range connections {
+		runs[i] = &hostRun{conn: conn}
+	}
+
+	hosts := tview.NewList().ShowSecondaryText(false)
+	hosts.SetBorder(true).SetTitle(currentLang["title_hosts"]).SetTitleAlign(tview.AlignLeft)
+	hosts.SetBackgroundColor(tcell.ColorNavy)
+	hosts.SetMainTextColor(tcell.ColorWhite)
+	hosts.SetSelectedTextColor(tcell.ColorWhite)
+	hosts.SetSelectedBackgroundColor(tcell.ColorDarkRed)
+
+	output := tview.NewTextView().SetScrollable(true)
+	output.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	output.SetBackgroundColor(tcell.ColorNavy)
+
+	summary := tview.NewTextView().SetDynamicColors(true)
+	summary.SetBackgroundColor(tcell.ColorNavy)
+
+	// render refreshes host statuses, the visible output and the summary
+	render := func() {
+		succeeded, failed, running := 0, 0, 0
+		for i, run := range runs {
+			_, isRunning, finished, code := run.state()
+			status := "[gray]…[-]"
+			switch {
+			case isRunning:
+				status = "[yellow]⟳[-]"
+				running++
+			case finished && code == 0:
+				status = "[green]✓[-]"
+				succeeded++
+			case finished:
+				status = fmt.Sprintf("[red]✗ %d[-]", code)
+				failed++
+			}
+			hosts.SetItemText(i, fmt.Sprintf("%s %s", status, tview.Escape(run.conn.Server)), "")
+		}
+
+		run := runs[hosts.GetCurrentItem()]
+		text, _, _, _ := run.state()
+		output.SetTitle(fmt.Sprintf(" %s: %s ", run.conn.Server, command))
+		if output.GetText(false) != text {
+			output.SetText(text)
+			output.ScrollToEnd()
+		}
+		summary.SetText(fmt.Sprintf(currentLang["run_summary"], succeeded, failed, running, len(runs)-succeeded-failed-running))
+	}
+	for _, run := range runs {
+		hosts.AddItem(tview.Escape(run.conn.Server), "", 0, nil)
+	}
+	hosts.SetChangedFunc(func(int, string, string, rune) {
+		output.Clear()
+		render()
+	})
+
+	hosts.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab:
+			app.SetFocus(output)
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+			return nil
+		}
+		return event
+	})
+	output.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab || event.Key() == tcell.KeyEscape {
+			app.SetFocus(hosts)
+			return nil
+		}
+		return event
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(tview.NewFlex().
+			AddItem(hosts, 30, 0, true).
+			AddItem(output, 0, 1, false), 0, 1, true).
+		AddItem(summary, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorNavy)
+	render()
+	app.SetRoot(layout, true)
+
+	// Run on all hosts with at most concurrency sessions at a time
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, run := range runs {
+		wg.Add(1)
+		go func(run *hostRun) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			run.mutex.Lock()
+			run.running = true
+			run.mutex.Unlock()
+			app.QueueUpdateDraw(render)
+
+			started := time.Now()
+			err := runRemoteCommand(ctx, run.conn, command, run)
+			if err != nil && exitCode(err) < 0 {
+				fmt.Fprintf(run, "\n%v\n", err)
+			}
+			recordHistory(run.conn.Server, historyRun, command, started, err)
+
+			run.mutex.Lock()
+			run.running = false
+			run.finished = true
+			run.exitCode = exitCode(err)
+			run.mutex.Unlock()
+			app.QueueUpdateDraw(render)
+		}(run)
+	}
+
+	// Stream output while commands are running
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				app.QueueUpdateDraw(render)
+			case <-finished:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,206 @@
+/*
+* Logs, history, recordings and tunnel files of an encrypted configuration
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Size of the key sealing the files written next to an encrypted config
+const logKeySize = 32
+
+// sealedLine is a line of a log or recording encrypted with the log key
+type sealedLine struct {
+	Sealed []byte `json:"sealed"` // nonce followed by the AES-GCM ciphertext
+}
+
+// ensureLogKey creates the log key of an unlocked encrypted config that has none yet
+// The key is stored inside the encrypted config, so changing the passphrase keeps the
+// files readable and the key stays with the config when it is decrypted
+func ensureLogKey() {
+	if !configEncrypted || configLocked || len(config.LogKey) == logKeySize {
+		return
+	}
+	key := make([]byte, logKeySize)
+	if _, err := rand.Read(key); err != nil {
+		log.Printf(currentLang["msg_save_error"], err)
+		return
+	}
+	config.LogKey = key
+	saveConnections()
+}
+
+// logCipher returns the AEAD of the log key, nil when the config has no key
+func logCipher() cipher.AEAD {
+	if len(config.LogKey) != logKeySize {
+		return nil
+	}
+	block, err := aes.NewCipher(config.LogKey)
+	if err != nil {
+		return nil
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil
+	}
+	return aead
+}
+
+// sealLine encrypts a line for writing while the config is encrypted; the result is
+// a single JSON line
+// Without the key, while the config is locked, the line is dropped: better a missing
+// line than a plaintext one
+func sealLine(line []byte) []byte {
+	if !configEncrypted {
+		return line
+	}
+	aead := logCipher()
+	if aead == nil {
+		return nil
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil
+	}
+	data, err := json.Marshal(sealedLine{Sealed: aead.Seal(nonce, nonce, line, nil)})
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// openLine returns a line as written: plaintext lines as they are and sealed lines
+// decrypted. Fails for sealed lines without the key of the unlocked config
+func openLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(line), []byte(`{"sealed":`)) {
+		return line, nil
+	}
+	var sealed sealedLine
+	if err := json.Unmarshal(line, &sealed); err != nil {
+		return nil, err
+	}
+	aead := logCipher()
+	if aead == nil || len(sealed.Sealed) < aead.NonceSize() {
+		return nil, errors.New(currentLang["msg_sealed_line"])
+	}
+	size := aead.NonceSize()
+	plain, err := aead.Open(nil, sealed.Sealed[:size], sealed.Sealed[size:], nil)
+	if err != nil {
+		return nil, errors.New(currentLang["msg_sealed_line"])
+	}
+	return plain, nil
+}
+
+// privateName returns the name of the server used in file names and the audit log:
+// the server itself, or while the config is encrypted a keyed hash of it, so the
+// hostnames stay inside the encrypted config
+func privateName(server string) string {
+	if !configEncrypted {
+		return server
+	}
+	return keyedName(server)
+}
+
+// keyedName returns # and the start of the HMAC of the server with the log key, # alone
+// without the key
+func keyedName(server string) string {
+	if len(config.LogKey) != logKeySize {
+		return "#"
+	}
+	mac := hmac.New(sha256.New, config.LogKey)
+	mac.Write([]byte(server))
+	return "#" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// serverOfPrivateName returns the connection or trashed connection with the private
+// name, the name itself when none matches
+func serverOfPrivateName(name string) string {
+	if !strings.HasPrefix(name, "#") || len(config.LogKey) != logKeySize {
+		return name
+	}
+	for _, conn := range sshConnections {
+		if keyedName(conn.Server) == name {
+			return conn.Server
+		}
+	}
+	for _, trashed := range config.Trash {
+		if keyedName(trashed.Server) == name {
+			return trashed.Server
+		}
+	}
+	return name
+}
+
+// sealedWriter seals every complete line written to it, for logs of command output
+type sealedWriter struct {
+	mutex   sync.Mutex
+	writer  io.Writer
+	pending []byte
+}
+
+func (w *sealedWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = append(w.pending, data...)
+	for {
+		end := bytes.IndexByte(w.pending, '\n')
+		if end < 0 {
+			return len(data), nil
+		}
+		if _, err := w.writer.Write(append(sealLine(w.pending[:end]), '\n')); err != nil {
+			return len(data), err
+		}
+		w.pending = w.pending[end+1:]
+	}
+}
+
+// Close writes the last incomplete line
+func (w *sealedWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	_, err := w.writer.Write(append(sealLine(w.pending), '\n'))
+	w.pending = nil
+	return err
+}
+
+// printSealedFile writes a log, history or recording file to standard output with
+// its sealed lines decrypted
+func printSealedFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, auditMaxLine)
+	for scanner.Scan() {
+		line, err := openLine(scanner.Bytes())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", line)
+	}
+	return scanner.Err()
+}
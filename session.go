@@ -0,0 +1,172 @@
+/*
+* Remote command, terminal and environment of interactive sessions
+ */
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// RequestTTY values, as in the ssh_config option
+const (
+	ttyDefault = ""
+	ttyYes     = "yes"
+	ttyNo      = "no"
+	ttyForce   = "force"
+	ttyAuto    = "auto"
+)
+
+// ttyModes returns the RequestTTY values and their display labels in dropdown order
+func ttyModes() ([]string, []string) {
+	return []string{ttyDefault, ttyYes, ttyNo, ttyForce, ttyAuto},
+		[]string{currentLang["tty_default"], ttyYes, ttyNo, ttyForce, ttyAuto}
+}
+
+// requestTTY returns the effective RequestTTY of the connection
+// A remote command gets a terminal unless configured otherwise, so commands like sudo -i work
+func requestTTY(conn SSHConnection) string {
+	if conn.RequestTTY == ttyDefault && conn.RemoteCommand != "" {
+		return ttyYes
+	}
+	return conn.RequestTTY
+}
+
+// sessionArgs builds the ssh command line of an interactive session: forwards, session
+// options, connection arguments and the remote command
+func sessionArgs(conn SSHConnection) []string {
+	args := forwardArgs(conn.Forwards)
+	if tty := requestTTY(conn); tty != ttyDefault {
+		args = append(args, "-o", "RequestTTY="+tty)
+	}
+	if len(conn.SendEnv) > 0 {
+		args = append(args, "-o", "SendEnv="+strings.Join(conn.SendEnv, " "))
+	}
+	if len(conn.SetEnv) > 0 {
+		// ssh keeps the first value of an option, so all variables go into one SetEnv
+		var variables []string
+		for _, name := range sortedKeys(conn.SetEnv) {
+			variable := name + "=" + conn.SetEnv[name]
+			if strings.ContainsAny(variable, " \t\"\\") {
+				variable = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(variable) + `"`
+			}
+			variables = append(variables, variable)
+		}
+		args = append(args, "-o", "SetEnv="+strings.Join(variables, " "))
+	}
+	args = append(args, sshArgs(conn)...)
+	if conn.RemoteCommand != "" {
+		args = append(args, conn.RemoteCommand)
+	}
+	return args
+}
+
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatEnv returns the variables as space separated NAME=value pairs for the form,
+// double-quoting values with spaces or quotes
+func formatEnv(values map[string]string) string {
+	pairs := make([]string, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		value := values[name]
+		if value == "" || strings.ContainsAny(value, " \t\"\\") {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// parseEnv parses space separated NAME=value pairs entered in the form
+// Values may be double-quoted, with \" and \\ escapes inside quotes
+func parseEnv(text string) (map[string]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, quoted, escaped := false, false, false
+	for _, char := range text {
+		switch {
+		case escaped:
+			field.WriteRune(char)
+			escaped = false
+		case quoted && char == '\\':
+			escaped = true
+		case char == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (char == ' ' || char == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(char)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, errors.New(currentLang["msg_env_format"])
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		name, value, found := strings.Cut(field, "=")
+		if !found || name == "" {
+			return nil, errors.New(currentLang["msg_env_format"])
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// nativeSessionEnv sets the environment of a built-in client session: the SetEnv variables
+// and local variables matching the SendEnv patterns. Servers may refuse variables
+// not allowed by AcceptEnv, which is ignored like ssh does
+func nativeSessionEnv(conn SSHConnection, session *ssh.Session) {
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		for _, pattern := range conn.SendEnv {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				_ = session.Setenv(name, value)
+				break
+			}
+		}
+	}
+	for _, name := range sortedKeys(conn.SetEnv) {
+		_ = session.Setenv(name, conn.SetEnv[name])
+	}
+}
+
+// nativeWantsTTY reports whether a built-in client session requests a remote PTY
+func nativeWantsTTY(conn SSHConnection, fd int) bool {
+	switch requestTTY(conn) {
+	case ttyNo:
+		return false
+	case ttyForce:
+		return true
+	case ttyYes:
+		return term.IsTerminal(fd)
+	}
+	// Like ssh, without RequestTTY a terminal is requested for login shells only
+	return conn.RemoteCommand == "" && term.IsTerminal(fd)
+}
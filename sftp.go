@@ -0,0 +1,508 @@
+/*
+* SFTP file browser
+ */
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pkg/sftp"
+	"github.com/rivo/tview"
+)
+
+// Width of the transfer progress bar in characters
+const progressWidth = 40
+
+// sftpPane is one side of the file browser, either the local or the remote file system
+type sftpPane struct {
+	table   *tview.Table
+	dir     string
+	entries []os.FileInfo
+	remote  bool
+}
+
+// sftpBrowser is a dual-pane local/remote file browser over an SFTP session
+type sftpBrowser struct {
+	app             *tview.Application
+	connectionsList *tview.List
+	client          *sftp.Client
+	closeSession    func()
+	local           *sftpPane
+	remote          *sftpPane
+	status          *tview.TextView
+	layout          *tview.Flex
+	busy            bool
+}
+
+// openSFTPBrowser starts an SFTP session for the connection and shows the file browser
+// The session is established with the application suspended so ssh can prompt for credentials
+func openSFTPBrowser(app *tview.Application, connectionsList *tview.List, conn SSHConnection) {
+	var client *sftp.Client
+	var closeSession func()
+	var err error
+	app.Suspend(func() {
+		log.Printf(currentLang["msg_connecting"], conn.Server)
+		client, closeSession, err = dialSFTP(conn)
+	})
+	if err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_sftp_error"], conn.Server, err))
+		return
+	}
+
+	browser := &sftpBrowser{
+		app:             app,
+		connectionsList: connectionsList,
+		client:          client,
+		closeSession:    closeSession,
+		status:          tview.NewTextView().SetDynamicColors(true),
+	}
+	browser.status.SetBackgroundColor(tcell.ColorNavy)
+	browser.status.SetText(currentLang["sftp_help"])
+
+	localDir, err := os.Getwd()
+	if err != nil {
+		localDir = os.Getenv("HOME")
+	}
+	remoteDir, err := client.Getwd()
+	if err != nil {
+		remoteDir = "."
+	}
+	browser.local = browser.newPane(localDir, false)
+	browser.remote = browser.newPane(remoteDir, true)
+
+	browser.layout = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(tview.NewFlex().
+			AddItem(browser.local.table, 0, 1, true).
+			AddItem(browser.remote.table, 0, 1, false), 0, 1, true).
+		AddItem(browser.status, 2, 0, false)
+	browser.layout.SetBackgroundColor(tcell.ColorNavy)
+
+	browser.reload(browser.local)
+	browser.reload(browser.remote)
+	browser.show(browser.local)
+}
+
+// dialSFTP opens an SFTP session over the built-in client or the system ssh sftp subsystem
+// Returns the client and a function closing the underlying connection
+func dialSFTP(conn SSHConnection) (*sftp.Client, func(), error) {
+	if conn.Client == clientNative {
+		sshClient, err := dialNative(conn, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		client, err := sftp.NewClient(sshClient)
+		if err != nil {
+			sshClient.Close()
+			return nil, nil, err
+		}
+		return client, func() { sshClient.Close() }, nil
+	}
+
+	args := append([]string{"-s"}, sshArgs(conn)...)
+	cmd := exec.Command("ssh", append(args, "sftp")...)
+	cmd.Env = credentialEnv(conn)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := sftp.NewClientPipe(stdout, stdin)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = errors.New(message)
+		}
+		return nil, nil, err
+	}
+	return client, func() {
+		stdin.Close()
+		_ = cmd.Wait()
+	}, nil
+}
+
+// newPane creates a file table for the given directory
+func (b *sftpBrowser) newPane(dir string, remote bool) *sftpPane {
+	pane := &sftpPane{table: tview.NewTable(), dir: dir, remote: remote}
+	pane.table.SetSelectable(true, false).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy)
+	pane.table.SetSelectedStyle(tcell.StyleDefault.Background(tcell.ColorDarkRed).Foreground(tcell.ColorWhite))
+
+	pane.table.SetSelectedFunc(func(row, column int) {
+		b.open(pane, row)
+	})
+	pane.table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab:
+			if pane.remote {
+				b.show(b.local)
+			} else {
+				b.show(b.remote)
+			}
+			return nil
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			b.open(pane, 0)
+			return nil
+		case tcell.KeyF5:
+			b.transfer(pane)
+			return nil
+		case tcell.KeyF6:
+			b.rename(pane)
+			return nil
+		case tcell.KeyF8:
+			b.remove(pane)
+			return nil
+		case tcell.KeyEscape, tcell.KeyF10:
+			b.close()
+			return nil
+		}
+		return event
+	})
+	return pane
+}
+
+// show makes the browser the application root and focuses the pane
+func (b *sftpBrowser) show(pane *sftpPane) {
+	b.app.SetRoot(b.layout, true)
+	b.app.SetFocus(pane.table)
+}
+
+// close ends the SFTP session and returns to the main screen
+func (b *sftpBrowser) close() {
+	if b.busy {
+		return
+	}
+	b.client.Close()
+	b.closeSession()
+	b.app.SetRoot(centerWidget(b.app, createMainLayout(b.app, b.connectionsList)), true)
+}
+
+// join returns the path of name inside the pane directory
+func (p *sftpPane) join(name string) string {
+	if p.remote {
+		return path.Join(p.dir, name)
+	}
+	return filepath.Join(p.dir, name)
+}
+
+// parent returns the parent directory of the pane directory
+func (p *sftpPane) parent() string {
+	if p.remote {
+		return path.Dir(p.dir)
+	}
+	return filepath.Dir(p.dir)
+}
+
+// selected returns the file under the cursor, nil for the parent directory entry
+func (p *sftpPane) selected() os.FileInfo {
+	row, _ := p.table.GetSelection()
+	if row <= 0 || row > len(p.entries) {
+		return nil
+	}
+	return p.entries[row-1]
+}
+
+// readDir lists the directory of the pane, directories first
+func (b *sftpBrowser) readDir(pane *sftpPane) ([]os.FileInfo, error) {
+	var entries []os.FileInfo
+	if pane.remote {
+		var err error
+		if entries, err = b.client.ReadDir(pane.dir); err != nil {
+			return nil, err
+		}
+	} else {
+		dirEntries, err := os.ReadDir(pane.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range dirEntries {
+			if info, err := entry.Info(); err == nil {
+				entries = append(entries, info)
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return strings.ToLower(entries[i].Name()) < strings.ToLower(entries[j].Name())
+	})
+	return entries, nil
+}
+
+// reload refreshes the pane contents, keeping the cursor row when possible
+func (b *sftpBrowser) reload(pane *sftpPane) {
+	entries, err := b.readDir(pane)
+	if err != nil {
+		b.setStatus(fmt.Sprintf("[yellow]%v[-]", err))
+		return
+	}
+	pane.entries = entries
+
+	title := currentLang["sftp_local"]
+	if pane.remote {
+		title = currentLang["sftp_remote"]
+	}
+	pane.table.SetTitle(fmt.Sprintf(" %s: %s ", title, pane.dir))
+
+	row, _ := pane.table.GetSelection()
+	pane.table.Clear()
+	pane.table.SetCell(0, 0, tview.NewTableCell("..").SetExpansion(1))
+	for i, entry := range entries {
+		name := tview.Escape(entry.Name())
+		size := formatSize(entry.Size())
+		if entry.IsDir() {
+			name = "[::b]" + name + "/"
+			size = ""
+		}
+		pane.table.SetCell(i+1, 0, tview.NewTableCell(name).SetExpansion(1))
+		pane.table.SetCell(i+1, 1, tview.NewTableCell(size).SetAlign(tview.AlignRight))
+	}
+	if row > len(entries) {
+		row = len(entries)
+	}
+	pane.table.Select(row, 0)
+}
+
+// open enters the directory at the row, row 0 is the parent directory
+func (b *sftpBrowser) open(pane *sftpPane, row int) {
+	if b.busy {
+		return
+	}
+	target := pane.parent()
+	if row > 0 {
+		entry := pane.entries[row-1]
+		if !entry.IsDir() {
+			return
+		}
+		target = pane.join(entry.Name())
+	}
+
+	previous := pane.dir
+	pane.dir = target
+	pane.table.Select(0, 0)
+	if _, err := b.readDir(pane); err != nil {
+		pane.dir = previous
+		b.setStatus(fmt.Sprintf("[yellow]%v[-]", err))
+		return
+	}
+	b.reload(pane)
+}
+
+// transfer copies the selected file to the other pane: uploads from local, downloads from remote
+func (b *sftpBrowser) transfer(pane *sftpPane) {
+	entry := pane.selected()
+	if b.busy || entry == nil {
+		return
+	}
+	if entry.IsDir() {
+		b.setStatus(fmt.Sprintf("[yellow]%s[-]", currentLang["msg_sftp_dir"]))
+		return
+	}
+
+	target := b.remote
+	if pane.remote {
+		target = b.local
+	}
+	source := pane.join(entry.Name())
+	destination := target.join(entry.Name())
+	total := entry.Size()
+
+	b.busy = true
+	go func() {
+		err := b.copyFile(pane.remote, source, destination, func(done int64) {
+			b.app.QueueUpdateDraw(func() {
+				b.status.SetText(fmt.Sprintf("%s %s", tview.Escape(progressBar(done, total)), tview.Escape(entry.Name())))
+			})
+		})
+		b.app.QueueUpdateDraw(func() {
+			b.busy = false
+			if err != nil {
+				b.setStatus(fmt.Sprintf("[yellow]%v[-]", err))
+				return
+			}
+			b.setStatus(fmt.Sprintf(currentLang["msg_sftp_done"], tview.Escape(entry.Name())))
+			b.reload(target)
+		})
+	}()
+}
+
+// copyFile copies a single file between the local and remote file systems
+// progress is called periodically with the number of bytes copied
+func (b *sftpBrowser) copyFile(download bool, source, destination string, progress func(int64)) error {
+	var reader io.ReadCloser
+	var writer io.WriteCloser
+	var err error
+	if download {
+		if reader, err = b.client.Open(source); err != nil {
+			return err
+		}
+		writer, err = os.Create(destination)
+	} else {
+		if reader, err = os.Open(source); err != nil {
+			return err
+		}
+		writer, err = b.client.Create(destination)
+	}
+	defer reader.Close()
+	if err != nil {
+		return err
+	}
+
+	counter := &progressWriter{writer: writer, report: progress}
+	if _, err = io.Copy(counter, reader); err != nil {
+		writer.Close()
+		return err
+	}
+	progress(counter.written)
+	return writer.Close()
+}
+
+// rename asks for a new name for the selected entry
+func (b *sftpBrowser) rename(pane *sftpPane) {
+	entry := pane.selected()
+	if b.busy || entry == nil {
+		return
+	}
+
+	form := newStyledForm()
+	form.AddInputField(currentLang["form_name"], entry.Name(), 40, nil, nil).
+		AddButton(currentLang["btn_save"], func() {
+			name := strings.TrimSpace(form.GetFormItem(0).(*tview.InputField).GetText())
+			if name != "" && name != entry.Name() {
+				var err error
+				if pane.remote {
+					err = b.client.Rename(pane.join(entry.Name()), pane.join(name))
+				} else {
+					err = os.Rename(pane.join(entry.Name()), pane.join(name))
+				}
+				if err != nil {
+					b.setStatus(fmt.Sprintf("[yellow]%v[-]", err))
+				}
+				b.reload(pane)
+			}
+			b.show(pane)
+		}).
+		AddButton(currentLang["btn_cancel"], func() {
+			b.show(pane)
+		})
+	form.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_rename"], entry.Name())).
+		SetTitleAlign(tview.AlignLeft)
+	b.app.SetRoot(centerBox(form, formWidth/2+10, 7), true)
+}
+
+// remove deletes the selected file or empty directory after confirmation
+func (b *sftpBrowser) remove(pane *sftpPane) {
+	entry := pane.selected()
+	if b.busy || entry == nil {
+		return
+	}
+
+	modal := tview.NewModal()
+	modal.SetBackgroundColor(tcell.ColorNavy)
+	modal.SetTextColor(tcell.ColorWhite)
+	modal.SetButtonBackgroundColor(tcell.ColorDarkRed)
+	modal.SetButtonTextColor(tcell.ColorWhite)
+	modal.
+		SetText(fmt.Sprintf(currentLang["dlg_delete_file"], entry.Name())).
+		AddButtons([]string{currentLang["btn_ok"], currentLang["btn_cancel"]}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			if buttonLabel == currentLang["btn_ok"] {
+				target := pane.join(entry.Name())
+				var err error
+				switch {
+				case pane.remote && entry.IsDir():
+					err = b.client.RemoveDirectory(target)
+				case pane.remote:
+					err = b.client.Remove(target)
+				default:
+					err = os.Remove(target)
+				}
+				if err != nil {
+					b.setStatus(fmt.Sprintf("[yellow]%v[-]", err))
+				}
+				b.reload(pane)
+			}
+			b.show(pane)
+		})
+	b.app.SetRoot(modal, true)
+}
+
+// setStatus shows a message above the key help line
+func (b *sftpBrowser) setStatus(message string) {
+	b.status.SetText(message + "\n" + currentLang["sftp_help"])
+}
+
+// progressWriter counts written bytes and reports progress at most every 100ms
+type progressWriter struct {
+	writer     io.Writer
+	written    int64
+	report     func(int64)
+	lastReport time.Time
+}
+
+func (w *progressWriter) Write(data []byte) (int, error) {
+	n, err := w.writer.Write(data)
+	w.written += int64(n)
+	if time.Since(w.lastReport) > 100*time.Millisecond {
+		w.lastReport = time.Now()
+		w.report(w.written)
+	}
+	return n, err
+}
+
+// progressBar renders a text progress bar like [#####-----]  50%
+func progressBar(done, total int64) string {
+	percent := 100
+	if total > 0 {
+		percent = int(done * 100 / total)
+	}
+	// Files growing during the transfer, such as logs, pass the listed size
+	if percent > 100 {
+		percent = 100
+	} else if percent < 0 {
+		percent = 0
+	}
+	filled := percent * progressWidth / 100
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), percent)
+}
+
+// formatSize returns a human readable file size
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"sshman/lang"
@@ -23,17 +24,49 @@ import (
 
 // Update the config structure by adding a new type
 type Config struct {
-	Connections []SSHConnection `json:"connections"`
-	Language    string          `json:"language"`
+	Connections   []SSHConnection     `json:"connections"`
+	Language      string              `json:"language"`
+	Terminal      string              `json:"terminal,omitempty"` // external terminal launcher template, e.g. "alacritty -e {ssh}"
+	Groups        []Group             `json:"groups,omitempty"`
+	Templates     []Template          `json:"templates,omitempty"`      // settings to start new connections from
+	Hooks         *Hooks              `json:"hooks,omitempty"`          // run around every session
+	LockTimeout   int                 `json:"lock_timeout,omitempty"`   // minutes of inactivity before an encrypted config locks, -1 never
+	AgentLifetime string              `json:"agent_lifetime,omitempty"` // ssh-add -t for keys added before connecting, default 1h, "0" unlimited
+	PersistUndo   bool                `json:"persist_undo,omitempty"`   // keep the undo journal between sessions
+	Trash         []TrashedConnection `json:"trash,omitempty"`          // deleted connections that can be restored
+	TrashDays     int                 `json:"trash_days,omitempty"`     // days before trashed connections are purged, default 30, -1 never
+	LogKey        []byte              `json:"log_key,omitempty"`        // seals history, logs and recordings of an encrypted config
 }
 
 type SSHConnection struct {
-	Server   string `json:"server"`
-	Comment  string `json:"comment"`
-	Port     string `json:"port"`
-	Username string `json:"username,omitempty"`
+	Server           string            `json:"server"`
+	Comment          string            `json:"comment"`
+	Port             string            `json:"port"`
+	Username         string            `json:"username,omitempty"`
+	IdentityFile     string            `json:"identity_file,omitempty"`
+	JumpHost         string            `json:"jump_host,omitempty"` // [user@]host[:port] passed to ssh -J
+	Forwards         []PortForward     `json:"forwards,omitempty"`
+	Client           string            `json:"client,omitempty"`   // "" or "exec" - system ssh, "native" - built-in client
+	Terminal         string            `json:"terminal,omitempty"` // overrides the global terminal launcher template
+	Group            string            `json:"group,omitempty"`    // name of a group in Config.Groups
+	Record           bool              `json:"record,omitempty"`   // record sessions in asciicast format
+	Hooks            *Hooks            `json:"hooks,omitempty"`
+	RemoteCommand    string            `json:"remote_command,omitempty"`    // run instead of the login shell
+	RequestTTY       string            `json:"request_tty,omitempty"`       // yes, no, force or auto as in ssh_config
+	SendEnv          []string          `json:"send_env,omitempty"`          // local variable name patterns to pass
+	SetEnv           map[string]string `json:"set_env,omitempty"`           // variables set on the remote side
+	Transport        string            `json:"transport,omitempty"`         // "" or "ssh", "mosh", "et", "custom"
+	TransportOptions string            `json:"transport_options,omitempty"` // extra mosh/et arguments or the custom command template
+	Reconnect        *ReconnectPolicy  `json:"reconnect,omitempty"`         // reconnect after network failures when set
+	Credential       string            `json:"credential,omitempty"`        // store of the password or key passphrase: keyring or pass
 }
 
+// Connection client backends
+const (
+	clientExec   = "exec"
+	clientNative = "native"
+)
+
 // Update global variables
 var (
 	sshConnections []SSHConnection
@@ -44,6 +77,7 @@ var (
 	config         Config // Add config variable
 	statusMutex    sync.RWMutex
 	hostOnline     = make(map[string]bool)
+	selected       = make(map[string]bool) // servers marked for multi-host actions
 )
 
 // Add constants for dimensions
@@ -51,6 +85,7 @@ const (
 	formWidth     = 100 // increase width for better readability
 	formHeight    = 60  // decrease height for compactness
 	contextHeight = 6   // Context menu height
+	maxFormHeight = 30  // forms growing taller lose the padding between fields
 	hostTimeout   = 2 * time.Second
 )
 
@@ -66,49 +101,158 @@ func createMainLayout(app *tview.Application, connectionsList *tview.List) *tvie
 	menuHeight := menuList.GetItemCount() + 2
 
 	// Calculate help height (text lines + border)
-	helpHeight := 8 // 6 text lines + top and bottom borders
+	helpHeight := 8 // status line + 7 text lines
 	// Calculate connections list height (number of connections + 1 + border)
 	connectionsHeight := len(sshConnections) + 3 // +1 for extra row, +2 for borders
 
+	// Help text keeps its width, the agent status takes the rest of the row
+	helpWidth := 0
+	for _, line := range strings.Split(currentLang["help_text"], "\n") {
+		if width := tview.TaggedStringWidth(line) + 1; width > helpWidth {
+			helpWidth = width
+		}
+	}
+	status := tview.NewFlex().
+		AddItem(helpText, helpWidth, 0, false).
+		AddItem(agentText, 0, 1, false)
+	bottom := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(statusText, 1, 0, false).
+		AddItem(status, 0, 1, false)
+
 	// Create vertical flex for lists and help text
 	return tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(connectionsList, connectionsHeight, 0, true).
 		AddItem(menuList, menuHeight, 0, false).
-		AddItem(helpText, helpHeight, 0, false)
+		AddItem(bottom, helpHeight, 0, false)
 }
 
 // sshConnect establishes an SSH connection to the specified server using the saved configuration
-// It supports custom port specification and handles connection errors
-func sshConnect(server string) {
-	var connection SSHConnection
-	for _, conn := range sshConnections {
-		if conn.Server == server {
-			connection = conn
+// It uses the system ssh binary or the built-in client depending on the connection settings
+// withForwards also opens the port forwards configured for the connection
+// Pre-connect hooks run first and abort the connect on failure, post-connect hooks run afterwards
+// With a reconnect policy, sessions ending in a network failure are reopened after a countdown
+func sshConnect(server string, withForwards bool) {
+	connection, _ := findConnection(server)
+	if !withForwards {
+		connection.Forwards = nil
+	}
+	// Resolved once, so a missing transport program falls back to ssh for the whole session
+	connection.Transport = sessionTransport(connection)
+
+	started := time.Now()
+	if err := runHooks(connection, hookPreConnect, 0); err != nil {
+		log.Printf(currentLang["msg_conn_error"], connection.Server, err)
+		recordHistory(connection.Server, historyConnect, hookPreConnect, started, err)
+		return
+	}
+	// A failed renewal is reported, ssh may still connect with the key alone
+	if err := renewCertificate(connection); err != nil {
+		log.Printf(currentLang["msg_conn_error"], connection.Server, err)
+	}
+
+	recordAudit(AuditEntry{Action: auditConnect, Server: connection.Server})
+	var err error
+	for attempt := 0; ; attempt++ {
+		log.Printf(currentLang["msg_connecting"], connection.Server)
+		started = time.Now()
+		var recording string
+		recording, err = runSession(connection)
+		if err != nil {
+			log.Printf(currentLang["msg_conn_error"], connection.Server, err)
+		}
+		details := recording
+		if attempt > 0 {
+			details = strings.TrimSpace(fmt.Sprintf(currentLang["history_reconnect"], attempt) + " " + recording)
+		}
+		recordHistory(connection.Server, historyConnect, details, started, err)
+
+		policy := connection.Reconnect
+		if policy == nil || !networkFailure(err) {
 			break
 		}
+		if time.Since(started) > reconnectStableTime {
+			attempt = 0
+		}
+		if attempt >= policy.attempts() ||
+			!reconnectCountdown(connection.Server, policy.delay(attempt+1), attempt+1, policy.attempts()) {
+			break
+		}
+	}
+
+	code := exitCode(err)
+	recordAudit(AuditEntry{Action: auditDisconnect, Server: connection.Server, ExitCode: &code})
+	if err := runHooks(connection, hookPostConnect, code); err != nil {
+		log.Printf(currentLang["msg_conn_error"], connection.Server, err)
 	}
-	sshCommand := "ssh"
+	// Time spent in the session counts as activity for the lock timeout
+	lastActivity = time.Now()
+}
+
+// runSession opens one interactive session of the connection with its client and transport,
+// recording it when enabled. Returns the path of the recording
+func runSession(connection SSHConnection) (string, error) {
+	switch {
+	case recordingEnabled(connection):
+		return recordSession(connection)
+	case connection.Client == clientNative && connection.Transport == transportSSH:
+		return "", nativeConnect(connection, os.Stdout, nil)
+	}
+	return "", execConnect(connection)
+}
+
+// sshArgs builds the ssh command line arguments for the connection
+// Supports custom port, identity file, jump host and username
+func sshArgs(connection SSHConnection) []string {
+	var args []string
 	if connection.Port != "" {
-		sshCommand = fmt.Sprintf("ssh -p %s", connection.Port)
+		args = append(args, "-p", connection.Port)
 	}
+	args = append(args, sshOptions(connection)...)
+	return append(args, sshTarget(connection))
+}
+
+// sshOptions returns the options shared by ssh, scp and rsync: identity file and jump host
+func sshOptions(connection SSHConnection) []string {
+	var args []string
+	if connection.IdentityFile != "" {
+		args = append(args, "-i", expandHome(connection.IdentityFile))
+	}
+	if connection.JumpHost != "" {
+		args = append(args, "-J", connection.JumpHost)
+	}
+	return args
+}
 
-	// Build the target with username if provided
-	target := connection.Server
+// sshTarget returns the server with username if provided
+func sshTarget(connection SSHConnection) string {
 	if connection.Username != "" {
-		target = fmt.Sprintf("%s@%s", connection.Username, connection.Server)
+		return fmt.Sprintf("%s@%s", connection.Username, connection.Server)
 	}
+	return connection.Server
+}
 
-	cmd := exec.Command("sh", "-c", fmt.Sprintf("%s %s", sshCommand, target))
+// execConnect runs the system ssh client or the transport program attached to the current terminal
+func execConnect(connection SSHConnection) error {
+	args := transportCommand(connection)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = credentialEnv(connection)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
 
-	log.Printf(currentLang["msg_connecting"], connection.Server)
-	err := cmd.Run()
-	if err != nil {
-		log.Printf(currentLang["msg_conn_error"], connection.Server, err)
+// expandHome replaces a leading ~ in the path with the user's home directory
+func expandHome(path string) string {
+	if path == "~" {
+		return os.Getenv("HOME")
 	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
 }
 
 // formatConnectionLine formats connection info with dots between address and description
@@ -122,20 +266,50 @@ func formatConnectionLine(conn SSHConnection) string {
 		serverPart = fmt.Sprintf("%s@%s", conn.Username, serverPart)
 	}
 
+	// Certificates that are expired or about to expire are flagged before the comment
+	comment := conn.Comment
+	commentLen := len(comment)
+	if warning, color := certWarningText(conn); warning != "" {
+		comment = fmt.Sprintf("[%s]%s[-] %s", color, warning, comment)
+		commentLen += len(warning) + 1
+	}
+
 	// Calculate available width - experimentally determined to fit the list width
 	totalWidth := formWidth - 4
 	serverLen := len(serverPart)
-	commentLen := len(conn.Comment)
 
 	// If both parts fit with at least 3 dots, use dots
 	if serverLen+commentLen+3 <= totalWidth {
 		dotsCount := totalWidth - serverLen - commentLen
 		dots := strings.Repeat(".", dotsCount)
-		return fmt.Sprintf("%s %s%s%s", getStatusSymbol(conn.Server), serverPart, dots, conn.Comment)
+		return fmt.Sprintf("%s%s%s%s%s", getStatusSymbol(conn.Server), getSelectionSymbol(conn.Server), serverPart, dots, comment)
 	}
 
 	// If too long, just use simple format
-	return fmt.Sprintf("%s %s - %s", getStatusSymbol(conn.Server), serverPart, conn.Comment)
+	return fmt.Sprintf("%s%s%s - %s", getStatusSymbol(conn.Server), getSelectionSymbol(conn.Server), serverPart, comment)
+}
+
+// getSelectionSymbol returns the marker shown between status and address of selected connections
+func getSelectionSymbol(server string) string {
+	if selected[server] {
+		return "[yellow]*[-]"
+	}
+	return " "
+}
+
+// selectedConnections returns the connections marked for multi-host actions,
+// or the connection at index when nothing is marked
+func selectedConnections(index int) []SSHConnection {
+	var connections []SSHConnection
+	for _, conn := range sshConnections {
+		if selected[conn.Server] {
+			connections = append(connections, conn)
+		}
+	}
+	if len(connections) == 0 && index >= 0 && index < len(sshConnections) {
+		connections = append(connections, sshConnections[index])
+	}
+	return connections
 }
 
 func setHostStatus(server string, isOnline bool) {
@@ -187,7 +361,7 @@ func connectionAddress(conn SSHConnection) string {
 }
 
 func checkHostOnline(conn SSHConnection) bool {
-	address := connectionAddress(conn)
+	address := connectionAddress(resolveConnection(conn))
 	if address == "" {
 		return false
 	}
@@ -210,7 +384,7 @@ func refreshConnectionsList(app *tview.Application, connectionsList *tview.List,
 
 	for i, conn := range sshConnections {
 		index := i
-		displayText := formatConnectionLine(conn)
+		displayText := formatConnectionLine(resolveConnection(conn))
 		connectionsList.AddItem(displayText, "", 0, func() {
 			showMessage(app, connectionsList, sshConnections[index].Server)
 		})
@@ -262,6 +436,29 @@ func centerWidget(app *tview.Application, widget tview.Primitive) *tview.Flex {
 		widgetHeight = screenHeight - 4 // Leave some margin
 	}
 
+	return centerBox(widget, widgetWidth, widgetHeight)
+}
+
+// centerForm centers a form container like centerWidget, growing it when the form
+// needs more rows than the main layout provides
+func centerForm(app *tview.Application, container tview.Primitive, form *tview.Form) *tview.Flex {
+	flex := centerWidget(app, container)
+	_, _, width, height := container.GetRect()
+	// Each field takes a row plus padding, then buttons, error line and borders
+	needed := form.GetFormItemCount()*2 + 4
+	if needed > maxFormHeight {
+		// Long forms drop the padding between fields to fit the screen
+		form.SetItemPadding(0)
+		needed = form.GetFormItemCount() + 7
+	}
+	if height < needed {
+		return centerBox(container, width, needed)
+	}
+	return flex
+}
+
+// centerBox centers the widget on the screen with the given fixed dimensions
+func centerBox(widget tview.Primitive, widgetWidth, widgetHeight int) *tview.Flex {
 	widget.SetRect(0, 0, widgetWidth, widgetHeight)
 	flex := tview.NewFlex().
 		AddItem(nil, 0, 1, false).
@@ -275,6 +472,29 @@ func centerWidget(app *tview.Application, widget tview.Primitive) *tview.Flex {
 	return flex
 }
 
+// findConnection returns the saved connection with the given server address, with the
+// defaults of its group applied
+func findConnection(server string) (SSHConnection, bool) {
+	for _, conn := range sshConnections {
+		if conn.Server == server {
+			return resolveConnection(conn), true
+		}
+	}
+	return SSHConnection{}, false
+}
+
+// copyConnection returns a deep copy of the connection, sharing no forwards, variables
+// or policies with it
+func copyConnection(conn SSHConnection) SSHConnection {
+	data, err := json.Marshal(conn)
+	if err != nil {
+		return conn
+	}
+	var copied SSHConnection
+	_ = json.Unmarshal(data, &copied)
+	return copied
+}
+
 // isConnectionExists checks if a connection with the given server address already exists
 // Returns true if the connection exists, false otherwise
 func isConnectionExists(server string) bool {
@@ -286,60 +506,217 @@ func isConnectionExists(server string) bool {
 	return false
 }
 
-// addConnection displays a form for adding a new SSH connection
-// Validates input and saves the new connection to the configuration
-func addConnection(app *tview.Application, connectionsList *tview.List) {
-	var form *tview.Form
-	errorText := tview.NewTextView().SetText("")
-	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
-	form = tview.NewForm()
+// newStyledForm creates an empty form with the application color scheme
+func newStyledForm() *tview.Form {
+	form := tview.NewForm()
 	form.SetBackgroundColor(tcell.ColorNavy)
 	form.SetFieldBackgroundColor(tcell.ColorDarkBlue)
 	form.SetFieldTextColor(tcell.ColorWhite)
 	form.SetLabelColor(tcell.ColorWhite)
 	form.SetButtonBackgroundColor(tcell.ColorDarkRed)
 	form.SetButtonTextColor(tcell.ColorWhite)
+	return form
+}
+
+// connectionClients returns the client backend values and their display labels in dropdown order
+func connectionClients() ([]string, []string) {
+	return []string{clientExec, clientNative},
+		[]string{currentLang["client_exec"], currentLang["client_native"]}
+}
+
+// addConnectionFields adds the connection input fields to the form, prefilled from connection
+// serverChanged is called whenever the server field changes
+func addConnectionFields(form *tview.Form, connection SSHConnection, serverChanged func(text string)) {
+	clients, clientLabels := connectionClients()
+	clientIndex := 0
+	for i, client := range clients {
+		if client == connection.Client {
+			clientIndex = i
+		}
+	}
+
+	transports, transportLabels := connectionTransports()
+	transportIndex := 0
+	for i, transport := range transports {
+		if transport == connection.Transport {
+			transportIndex = i
+		}
+	}
+
+	stores, storeLabels := credentialStores()
+	storeIndex := 0
+	for i, store := range stores {
+		if store == connection.Credential {
+			storeIndex = i
+		}
+	}
+
+	ttyValues, ttyLabels := ttyModes()
+	ttyIndex := 0
+	for i, tty := range ttyValues {
+		if tty == connection.RequestTTY {
+			ttyIndex = i
+		}
+	}
 
 	form.
-		AddInputField(currentLang["form_server"], "", 30, nil, func(text string) {
-			if text == "" {
-				return
-			}
-			if isConnectionExists(text) {
-				errorText.SetText(currentLang["msg_conn_exists"])
+		AddInputField(currentLang["form_server"], connection.Server, 30, nil, serverChanged).
+		AddInputField(currentLang["form_port"], connection.Port, 5, nil, nil).
+		AddInputField(currentLang["form_comment"], connection.Comment, 30, nil, nil).
+		AddInputField(currentLang["form_username"], connection.Username, 20, nil, nil).
+		AddInputField(currentLang["form_identity"], connection.IdentityFile, 40, nil, nil).
+		AddInputField(currentLang["form_jump_host"], connection.JumpHost, 30, nil, nil).
+		AddDropDown(currentLang["form_credential"], storeLabels, storeIndex, nil).
+		AddPasswordField(currentLang["form_secret"], "", 30, '*', nil).
+		AddDropDown(currentLang["form_client"], clientLabels, clientIndex, nil).
+		AddDropDown(currentLang["form_transport"], transportLabels, transportIndex, nil).
+		AddInputField(currentLang["form_transport_options"], connection.TransportOptions, 40, nil, nil).
+		AddInputField(currentLang["form_terminal"], connection.Terminal, 40, nil, nil).
+		AddInputField(currentLang["form_group"], connection.Group, 20, nil, nil).
+		AddCheckbox(currentLang["form_record"], connection.Record, nil).
+		AddCheckbox(currentLang["form_reconnect"], connection.Reconnect != nil, nil).
+		AddInputField(currentLang["form_remote_command"], connection.RemoteCommand, 40, nil, nil).
+		AddDropDown(currentLang["form_request_tty"], ttyLabels, ttyIndex, nil).
+		AddInputField(currentLang["form_set_env"], formatEnv(connection.SetEnv), 40, nil, nil).
+		AddInputField(currentLang["form_send_env"], strings.Join(connection.SendEnv, " "), 40, nil, nil)
+}
+
+// formSecret returns the password or passphrase entered in a connection form, empty to keep the stored one
+func formSecret(form *tview.Form) string {
+	return form.GetFormItemByLabel(currentLang["form_secret"]).(*tview.InputField).GetText()
+}
+
+// readConnectionForm returns base updated with the values entered in a form built by addConnectionFields
+// Returns an error for values that cannot be parsed
+func readConnectionForm(form *tview.Form, base SSHConnection) (SSHConnection, error) {
+	text := func(label string) string {
+		return strings.TrimSpace(form.GetFormItemByLabel(currentLang[label]).(*tview.InputField).GetText())
+	}
+
+	connection := base
+	connection.Server = text("form_server")
+	connection.Port = text("form_port")
+	connection.Comment = text("form_comment")
+	connection.Username = text("form_username")
+	connection.IdentityFile = text("form_identity")
+	connection.JumpHost = text("form_jump_host")
+	connection.Terminal = text("form_terminal")
+	connection.Group = text("form_group")
+	connection.Record = form.GetFormItemByLabel(currentLang["form_record"]).(*tview.Checkbox).IsChecked()
+	// Keep the configured attempts and delay when the policy stays enabled
+	if !form.GetFormItemByLabel(currentLang["form_reconnect"]).(*tview.Checkbox).IsChecked() {
+		connection.Reconnect = nil
+	} else if connection.Reconnect == nil {
+		connection.Reconnect = &ReconnectPolicy{}
+	}
+	connection.RemoteCommand = text("form_remote_command")
+	connection.SendEnv = strings.Fields(text("form_send_env"))
+
+	clients, _ := connectionClients()
+	clientIndex, _ := form.GetFormItemByLabel(currentLang["form_client"]).(*tview.DropDown).GetCurrentOption()
+	connection.Client = ""
+	if clientIndex > 0 {
+		connection.Client = clients[clientIndex]
+	}
+
+	stores, _ := credentialStores()
+	storeIndex, _ := form.GetFormItemByLabel(currentLang["form_credential"]).(*tview.DropDown).GetCurrentOption()
+	connection.Credential = stores[storeIndex]
+
+	transports, _ := connectionTransports()
+	transportIndex, _ := form.GetFormItemByLabel(currentLang["form_transport"]).(*tview.DropDown).GetCurrentOption()
+	connection.Transport = ""
+	if transportIndex > 0 {
+		connection.Transport = transports[transportIndex]
+	}
+	connection.TransportOptions = text("form_transport_options")
+
+	ttyValues, _ := ttyModes()
+	ttyIndex, _ := form.GetFormItemByLabel(currentLang["form_request_tty"]).(*tview.DropDown).GetCurrentOption()
+	connection.RequestTTY = ttyValues[ttyIndex]
+
+	setEnv, err := parseEnv(text("form_set_env"))
+	if err != nil {
+		return connection, err
+	}
+	connection.SetEnv = setEnv
+	return connection, nil
+}
+
+// addConnection displays a form for adding a new SSH connection
+// Validates input and saves the new connection to the configuration
+func addConnection(app *tview.Application, connectionsList *tview.List) {
+	addConnectionFrom(app, connectionsList, SSHConnection{}, currentLang["title_add"])
+}
+
+// cloneConnection opens the add form prefilled with a copy of the connection at the index
+func cloneConnection(app *tview.Application, connectionsList *tview.List, index int) {
+	if index < 0 || index >= len(sshConnections) {
+		return
+	}
+	clone := copyConnection(sshConnections[index])
+	addConnectionFrom(app, connectionsList, clone, fmt.Sprintf(currentLang["title_clone"], clone.Server))
+}
+
+// addConnectionFrom displays the add form prefilled from base; settings without a form
+// field, like forwards and hooks, are taken from base as well
+func addConnectionFrom(app *tview.Application, connectionsList *tview.List, base SSHConnection, title string) {
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+	form := newStyledForm()
+
+	// Templates only start new connections, a clone has its settings already
+	if base.Server == "" {
+		addTemplateField(form)
+	}
+	addConnectionFields(form, base, func(text string) {
+		if text == "" {
+			return
+		}
+		if isConnectionExists(text) {
+			errorText.SetText(currentLang["msg_conn_exists"])
+			return
+		}
+		errorText.SetText("")
+	})
+	showGroupDefaults(form)
+	form.
+		AddButton(currentLang["btn_save"], func() {
+			connection, err := readConnectionForm(form, base)
+			if err != nil {
+				errorText.SetText(err.Error())
 				return
 			}
-			errorText.SetText("")
-		}).
-		AddInputField(currentLang["form_port"], "", 5, nil, nil).
-		AddInputField(currentLang["form_comment"], "", 30, nil, nil).
-		AddInputField(currentLang["form_username"], "", 20, nil, nil).
-		AddButton(currentLang["btn_save"], func() {
-			server := form.GetFormItem(0).(*tview.InputField).GetText()
-			port := form.GetFormItem(1).(*tview.InputField).GetText()
-			comment := form.GetFormItem(2).(*tview.InputField).GetText()
-			username := form.GetFormItem(3).(*tview.InputField).GetText()
+			server := connection.Server
 
 			if server == "" {
 				errorText.SetText(currentLang["msg_enter_server"])
 				return
 			}
-			if comment == "" {
+			if connection.Comment == "" {
 				errorText.SetText(currentLang["msg_enter_comment"])
 				return
 			}
+			if isConnectionExists(server) {
+				errorText.SetText(currentLang["msg_conn_exists"])
+				return
+			}
 
-			if !isConnectionExists(server) {
-				connection := SSHConnection{Server: server, Port: port, Comment: comment, Username: username}
-				sshConnections = append(sshConnections, connection)
-				setHostStatus(server, false)
-				saveConnections()
-				refreshConnectionsList(app, connectionsList, len(sshConnections)-1)
-				checkHostsOnline(app, connectionsList, []SSHConnection{connection})
-
-				// Return to main screen
-				app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+			if err := applyCredential(SSHConnection{}, connection, formSecret(form)); err != nil {
+				errorText.SetText(fmt.Sprintf(currentLang["msg_credential_error"], err))
+				return
 			}
+			before := journalSnapshot()
+			sshConnections = append(sshConnections, connection)
+			setHostStatus(server, false)
+			saveConnections()
+			auditChange(auditAdd, nil, &connection)
+			recordOperation(fmt.Sprintf(currentLang["op_add"], server), before)
+			refreshConnectionsList(app, connectionsList, len(sshConnections)-1)
+			checkHostsOnline(app, connectionsList, []SSHConnection{connection})
+
+			// Return to main screen
+			app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
 		}).
 		AddButton(currentLang["btn_cancel"], func() {
 			app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
@@ -352,20 +729,31 @@ func addConnection(app *tview.Application, connectionsList *tview.List) {
 		AddItem(errorText, 1, 0, false)
 
 	formFlex.SetBorder(true).
-		SetTitle(currentLang["title_add"]).
+		SetTitle(title).
 		SetTitleAlign(tview.AlignLeft).
 		SetBackgroundColor(tcell.ColorNavy).
 		SetBorderColor(tcell.ColorWhite).
 		SetTitleColor(tcell.ColorWhite)
 
-	// Set form as active widget
-	app.SetRoot(centerWidget(app, formFlex), true)
+	// A clone keeps the name of its source until the server field is changed
+	if isConnectionExists(base.Server) {
+		errorText.SetText(currentLang["msg_conn_exists"])
+	}
+
+	// Set form as active widget, starting at the server field
+	app.SetRoot(centerForm(app, formFlex, form), true)
+	form.SetFocus(0)
 	app.SetFocus(form)
 }
 
 // saveConnections writes the current connections list to the configuration file
 // Creates the config directory if it doesn't exist
 func saveConnections() {
+	// Never overwrite an encrypted config that was not unlocked
+	if configLocked {
+		return
+	}
+
 	// Ensure the config directory exists
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		log.Printf(currentLang["msg_config_dir_error"], err)
@@ -387,6 +775,10 @@ func saveConnections() {
 	}
 
 	data = append(data, '\n')
+	if data, err = sealConfigFile(data); err != nil {
+		log.Printf(currentLang["msg_save_error"], err)
+		return
+	}
 	err = os.WriteFile(configFilePath, data, 0644)
 	if err != nil {
 		log.Printf(currentLang["msg_write_error"], err)
@@ -396,9 +788,10 @@ func saveConnections() {
 // loadConnections reads and parses the SSH connections from the configuration file
 // Silently handles the case when the config file doesn't exist
 func loadConnections() {
-	data, err := os.ReadFile(configFilePath)
+	data, err := readConfigFile()
 	if err != nil {
-		if !os.IsNotExist(err) {
+		// A locked config is reported by the unlock prompt
+		if !os.IsNotExist(err) && !configLocked {
 			log.Printf(currentLang["msg_read_error"], err)
 		}
 		return
@@ -429,22 +822,158 @@ func showMessage(app *tview.Application, list *tview.List, server string) {
 	modal.SetTextColor(tcell.ColorWhite)
 	modal.SetButtonBackgroundColor(tcell.ColorDarkRed)
 	modal.SetButtonTextColor(tcell.ColorWhite)
+	// The session can also open in an external terminal or next to sshman inside tmux or screen
+	buttons := []string{currentLang["btn_ok"]}
+	if conn, _ := findConnection(server); terminalTemplate(conn) != "" {
+		buttons = append(buttons, currentLang["btn_terminal"])
+	}
+	switch detectMultiplexer() {
+	case multiplexerTmux:
+		buttons = append(buttons, currentLang["btn_new_window"], currentLang["btn_split_pane"])
+	case multiplexerScreen:
+		buttons = append(buttons, currentLang["btn_new_window"])
+	}
+	buttons = append(buttons, currentLang["btn_cancel"])
+
 	modal.
 		SetText(fmt.Sprintf(currentLang["dlg_connect"], server)).
-		AddButtons([]string{currentLang["btn_ok"], currentLang["btn_cancel"]}).
+		AddButtons(buttons).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			if buttonLabel == currentLang["btn_ok"] {
+			var err error
+			switch buttonLabel {
+			case currentLang["btn_ok"]:
+				// A passphrase protected key can go to the agent first
+				if conn, _ := findConnection(server); conn.IdentityFile != "" {
+					if path, missing := agentMissingKey(conn); missing {
+						offerAgentKey(app, list, server, path)
+						return
+					}
+				}
 				app.Suspend(func() {
-					sshConnect(server)
+					sshConnect(server, false)
 				})
+				// The session may have renewed the certificate or loaded keys
+				refreshConnectionsList(app, list, list.GetCurrentItem())
+				reloadAgentStatus(app, list.GetCurrentItem())
+			case currentLang["btn_terminal"]:
+				conn, _ := findConnection(server)
+				err = openInTerminal(conn)
+			case currentLang["btn_new_window"]:
+				conn, _ := findConnection(server)
+				err = openInWindow(conn)
+			case currentLang["btn_split_pane"]:
+				conn, _ := findConnection(server)
+				err = openInPane(conn)
+			}
+			if err != nil {
+				showError(app, list, fmt.Sprintf(currentLang["msg_conn_error"], server, err))
+				return
 			}
 			app.SetRoot(centerWidget(app, createMainLayout(app, list)), true)
 		})
 	app.SetRoot(centerWidget(app, modal), true)
 }
 
+// showError displays an error message and returns to the main screen
+func showError(app *tview.Application, list *tview.List, message string) {
+	modal := tview.NewModal()
+	modal.SetBackgroundColor(tcell.ColorNavy)
+	modal.SetTextColor(tcell.ColorWhite)
+	modal.SetButtonBackgroundColor(tcell.ColorDarkRed)
+	modal.SetButtonTextColor(tcell.ColorWhite)
+	modal.
+		SetText(message).
+		AddButtons([]string{currentLang["btn_ok"]}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			app.SetRoot(centerWidget(app, createMainLayout(app, list)), true)
+		})
+	app.SetRoot(centerWidget(app, modal), true)
+}
+
+// showConnectionActions displays the context menu with actions for the selected connection
+func showConnectionActions(app *tview.Application, connectionsList *tview.List, index int) {
+	if index < 0 || index >= len(sshConnections) {
+		return
+	}
+
+	server := sshConnections[index].Server
+	backToMain := func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	}
+
+	actions := tview.NewList().ShowSecondaryText(false)
+	actions.SetTitle(fmt.Sprintf(currentLang["ctx_actions"], server)).SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	actions.SetBackgroundColor(tcell.ColorNavy)
+	actions.SetMainTextColor(tcell.ColorWhite)
+	actions.SetSelectedTextColor(tcell.ColorWhite)
+	actions.SetSelectedBackgroundColor(tcell.ColorDarkRed)
+
+	actions.AddItem(" "+currentLang["ctx_connect"], "", 0, func() {
+		showMessage(app, connectionsList, server)
+	})
+	actions.AddItem(" "+currentLang["ctx_edit"], "", 0, func() {
+		editConnection(app, connectionsList, index)
+	})
+	actions.AddItem(" "+currentLang["ctx_clone"], "", 0, func() {
+		cloneConnection(app, connectionsList, index)
+	})
+	actions.AddItem(" "+currentLang["ctx_bulk_edit"], "", 0, func() {
+		bulkEditForm(app, connectionsList, selectedConnections(index))
+	})
+	actions.AddItem(" "+currentLang["ctx_details"], "", 0, func() {
+		showConnectionDetails(app, connectionsList, index)
+	})
+	actions.AddItem(" "+currentLang["ctx_connect_forwards"], "", 0, func() {
+		app.Suspend(func() {
+			sshConnect(server, true)
+		})
+		backToMain()
+	})
+	actions.AddItem(" "+currentLang["ctx_start_tunnel"], "", 0, func() {
+		startTunnel(app, connectionsList, resolveConnection(sshConnections[index]))
+	})
+	actions.AddItem(" "+currentLang["ctx_persistent_tunnel"], "", 0, func() {
+		startPersistentTunnelFromUI(app, connectionsList, resolveConnection(sshConnections[index]))
+	})
+	actions.AddItem(" "+currentLang["ctx_forwards"], "", 0, func() {
+		showForwards(app, connectionsList, index)
+	})
+	actions.AddItem(" "+currentLang["ctx_sftp"], "", 0, func() {
+		openSFTPBrowser(app, connectionsList, resolveConnection(sshConnections[index]))
+	})
+	actions.AddItem(" "+currentLang["ctx_copy"], "", 0, func() {
+		copyFiles(app, connectionsList, resolveConnection(sshConnections[index]))
+	})
+	actions.AddItem(" "+currentLang["ctx_history"], "", 0, func() {
+		showHistory(app, connectionsList, server)
+	})
+	actions.AddItem(" "+currentLang["ctx_run_command"], "", 0, func() {
+		runCommandForm(app, connectionsList, resolveConnections(selectedConnections(index)))
+	})
+	actions.AddItem(" "+currentLang["ctx_deploy_key"], "", 0, func() {
+		deployKeyForm(app, connectionsList, resolveConnections(selectedConnections(index)))
+	})
+	if detectMultiplexer() == multiplexerTmux {
+		actions.AddItem(" "+currentLang["ctx_sync_panes"], "", 0, func() {
+			if err := openSynchronizedPanes(selectedConnections(index)); err != nil {
+				showError(app, connectionsList, fmt.Sprintf(currentLang["msg_conn_error"], server, err))
+				return
+			}
+			backToMain()
+		})
+	}
+	actions.AddItem(" "+currentLang["ctx_cancel"], "", 0, backToMain)
+
+	actions.SetDoneFunc(backToMain)
+	app.SetRoot(centerBox(actions, formWidth/2, actions.GetItemCount()+2), true)
+}
+
 // openConfig opens the configuration file in the default system editor
 func openConfig() {
+	if configEncrypted {
+		log.Print(currentLang["msg_config_encrypted_edit"])
+		return
+	}
 	cmd := exec.Command("open", configFilePath)
 	err := cmd.Run()
 	if err != nil {
@@ -470,11 +999,16 @@ func deleteConnection(app *tview.Application, list *tview.List, index int) {
 		AddButtons([]string{currentLang["btn_ok"], currentLang["btn_cancel"]}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 			if buttonLabel == currentLang["btn_ok"] {
-				// Remove from slice
-				sshConnections = append(sshConnections[:index], sshConnections[index+1:]...)
+				removed := sshConnections[index]
+				before := journalSnapshot()
+				trashConnection(index)
 				deleteHostStatus(server)
+				delete(selected, server)
 				// Save changes
 				saveConnections()
+				auditChange(auditDelete, &removed, nil)
+				recordOperation(fmt.Sprintf(currentLang["op_delete"], server), before)
+				populateMenu(app, list)
 				refreshIndex := index
 				if refreshIndex >= len(sshConnections) {
 					refreshIndex = len(sshConnections) - 1
@@ -494,59 +1028,80 @@ func editConnection(app *tview.Application, connectionsList *tview.List, index i
 	}
 
 	connection := sshConnections[index]
-	var form *tview.Form
 	errorText := tview.NewTextView().SetText("")
 	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
-	form = tview.NewForm()
-	form.SetBackgroundColor(tcell.ColorNavy)
-	form.SetFieldBackgroundColor(tcell.ColorDarkBlue)
-	form.SetFieldTextColor(tcell.ColorWhite)
-	form.SetLabelColor(tcell.ColorWhite)
-	form.SetButtonBackgroundColor(tcell.ColorDarkRed)
-	form.SetButtonTextColor(tcell.ColorWhite)
+	form := newStyledForm()
 
+	addConnectionFields(form, connection, func(text string) {
+		if text == "" {
+			return
+		}
+		if text != connection.Server && isConnectionExists(text) {
+			errorText.SetText(currentLang["msg_conn_exists"])
+			return
+		}
+		errorText.SetText("")
+	})
+	showGroupDefaults(form)
 	form.
-		AddInputField(currentLang["form_server"], connection.Server, 30, nil, func(text string) {
-			if text == "" {
-				return
-			}
-			if text != connection.Server && isConnectionExists(text) {
-				errorText.SetText(currentLang["msg_conn_exists"])
+		AddButton(currentLang["btn_save"], func() {
+			updatedConn, err := readConnectionForm(form, connection)
+			if err != nil {
+				errorText.SetText(err.Error())
 				return
 			}
-			errorText.SetText("")
-		}).
-		AddInputField(currentLang["form_port"], connection.Port, 5, nil, nil).
-		AddInputField(currentLang["form_comment"], connection.Comment, 30, nil, nil).
-		AddInputField(currentLang["form_username"], connection.Username, 20, nil, nil).
-		AddButton(currentLang["btn_save"], func() {
-			server := form.GetFormItem(0).(*tview.InputField).GetText()
-			port := form.GetFormItem(1).(*tview.InputField).GetText()
-			comment := form.GetFormItem(2).(*tview.InputField).GetText()
-			username := form.GetFormItem(3).(*tview.InputField).GetText()
+			server := updatedConn.Server
 
 			if server == "" {
 				errorText.SetText(currentLang["msg_enter_server"])
 				return
 			}
-			if comment == "" {
+			if updatedConn.Comment == "" {
 				errorText.SetText(currentLang["msg_enter_comment"])
 				return
 			}
 
 			if server == connection.Server || !isConnectionExists(server) {
-				updatedConn := SSHConnection{Server: server, Port: port, Comment: comment, Username: username}
+				if err := applyCredential(connection, updatedConn, formSecret(form)); err != nil {
+					errorText.SetText(fmt.Sprintf(currentLang["msg_credential_error"], err))
+					return
+				}
+				before := journalSnapshot()
 				sshConnections[index] = updatedConn
 				if server != connection.Server {
 					deleteHostStatus(connection.Server)
+					if selected[connection.Server] {
+						delete(selected, connection.Server)
+						selected[server] = true
+					}
 				}
 				setHostStatus(server, false)
 				saveConnections()
+				auditChange(auditEdit, &connection, &updatedConn)
+				recordOperation(fmt.Sprintf(currentLang["op_edit"], server), before)
 				refreshConnectionsList(app, connectionsList, index)
 				checkHostsOnline(app, connectionsList, []SSHConnection{updatedConn})
 				app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
 			}
 		}).
+		AddButton(currentLang["btn_clear_credential"], func() {
+			if connection.Credential == "" {
+				return
+			}
+			if err := clearCredential(connection.Credential, connection.Server); err != nil {
+				errorText.SetText(fmt.Sprintf(currentLang["msg_credential_error"], err))
+				return
+			}
+			// The secret is gone, so neither the saved connection nor undo may refer to it
+			before := connection
+			forgetCredentialInJournal(credentialKey{connection.Credential, connection.Server})
+			connection.Credential = ""
+			sshConnections[index].Credential = ""
+			saveConnections()
+			auditChange(auditEdit, &before, &connection)
+			form.GetFormItemByLabel(currentLang["form_credential"]).(*tview.DropDown).SetCurrentOption(0)
+			errorText.SetText(currentLang["msg_credential_cleared"])
+		}).
 		AddButton(currentLang["btn_cancel"], func() {
 			app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
 		})
@@ -562,7 +1117,36 @@ func editConnection(app *tview.Application, connectionsList *tview.List, index i
 		SetBackgroundColor(tcell.ColorNavy).
 		SetBorderColor(tcell.ColorWhite).
 		SetTitleColor(tcell.ColorWhite)
-	app.SetRoot(centerWidget(app, formFlex), true)
+	app.SetRoot(centerForm(app, formFlex, form), true)
+}
+
+// populateMenu fills the main menu with items in the current language
+func populateMenu(app *tview.Application, connectionsList *tview.List) {
+	menuList.Clear()
+	menuList.AddItem(" "+currentLang["menu_add"], "", 0, func() {
+		addConnection(app, connectionsList)
+	})
+	menuList.AddItem(" "+currentLang["menu_tunnels"], "", 0, func() {
+		showTunnels(app, connectionsList)
+	})
+	menuList.AddItem(" "+currentLang["menu_recordings"], "", 0, func() {
+		showRecordings(app, connectionsList)
+	})
+	menuList.AddItem(" "+currentLang["menu_keys"], "", 0, func() {
+		showKeys(app, connectionsList, "")
+	})
+	menuList.AddItem(" "+fmt.Sprintf(currentLang["menu_trash"], len(config.Trash)), "", 0, func() {
+		showTrash(app, connectionsList)
+	})
+	menuList.AddItem(" "+currentLang["menu_language"], "", 0, func() {
+		switchLanguage(app, connectionsList)
+	})
+	menuList.AddItem(" "+currentLang["menu_edit_config"], "", 0, func() {
+		openConfig()
+	})
+	menuList.AddItem(" "+currentLang["menu_exit"], "", 0, func() {
+		app.Stop()
+	})
 }
 
 // Add language switching function
@@ -588,22 +1172,11 @@ func switchLanguage(app *tview.Application, connectionsList *tview.List) {
 			helpText.SetText(currentLang["help_text"])
 
 			// Update menu items
-			menuList.Clear()
-			menuList.AddItem(" "+currentLang["menu_add"], "", 0, func() {
-				addConnection(app, connectionsList)
-			})
-			menuList.AddItem(" "+currentLang["menu_language"], "", 0, func() {
-				switchLanguage(app, connectionsList)
-			})
-			menuList.AddItem(" "+currentLang["menu_edit_config"], "", 0, func() {
-				openConfig()
-			})
-			menuList.AddItem(" "+currentLang["menu_exit"], "", 0, func() {
-				app.Stop()
-			})
+			populateMenu(app, connectionsList)
 
 			currentIndex := connectionsList.GetCurrentItem()
 			refreshConnectionsList(app, connectionsList, currentIndex)
+			refreshAgentStatus(app, currentIndex)
 
 			// Save config with new language
 			saveConnections()
@@ -628,17 +1201,73 @@ func setupDebianTheme() {
 	tview.Styles.ContrastSecondaryTextColor = tcell.ColorWhite
 }
 
+// uiScreen is the terminal of the application; it knows when the terminal is handed
+// over to ssh or another program with app.Suspend
+type uiScreen struct {
+	tcell.Screen
+	suspended int32
+}
+
+// Init does nothing because main initializes the screen before handing it to the application
+func (s *uiScreen) Init() error {
+	return nil
+}
+
+func (s *uiScreen) Suspend() error {
+	atomic.StoreInt32(&s.suspended, 1)
+	return s.Screen.Suspend()
+}
+
+func (s *uiScreen) Resume() error {
+	err := s.Screen.Resume()
+	atomic.StoreInt32(&s.suspended, 0)
+	return err
+}
+
+// statusWriter sends log output to the status line while the UI is shown and to the
+// terminal while it is suspended
+type statusWriter struct {
+	app    *tview.Application
+	screen *uiScreen
+}
+
+func (w statusWriter) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&w.screen.suspended) != 0 {
+		return os.Stderr.Write(p)
+	}
+	message := strings.TrimSpace(string(p))
+	// Queued from a new goroutine, because the UI goroutine itself may be logging
+	go w.app.QueueUpdateDraw(func() {
+		setStatus(message, tcell.ColorYellow)
+	})
+	return len(p), nil
+}
+
 // main initializes and runs the SSH connection manager application
 // Sets up the UI, loads configuration and handles user input
 func main() {
+	// ssh runs sshman as SSH_ASKPASS to answer prompts from the credential store
+	if os.Getenv(askpassServerEnv) != "" {
+		os.Exit(askpassCommand(os.Args[1:]))
+	}
+
+	// Load connections from file
+	loadConnections()
+
+	// Run command line subcommands without the UI
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	app := tview.NewApplication()
 
+	// Undo history of the previous session, when kept
+	loadJournal()
+	purgeExpiredTrash()
+
 	// Apply Debian installer theme
 	setupDebianTheme()
 
-	// Load connections from file
-	loadConnections()
-
 	// Create connections list
 	connectionsList := tview.NewList().ShowSecondaryText(false)
 	connectionsList.SetTitle(currentLang["connections_title"]).SetBorder(true).SetTitleAlign(tview.AlignLeft)
@@ -660,18 +1289,7 @@ func main() {
 	refreshConnectionsList(app, connectionsList, 0)
 
 	// Add menu items with left padding
-	menuList.AddItem(" "+currentLang["menu_add"], "", 0, func() {
-		addConnection(app, connectionsList)
-	})
-	menuList.AddItem(" "+currentLang["menu_language"], "", 0, func() {
-		switchLanguage(app, connectionsList)
-	})
-	menuList.AddItem(" "+currentLang["menu_edit_config"], "", 0, func() {
-		openConfig()
-	})
-	menuList.AddItem(" "+currentLang["menu_exit"], "", 0, func() {
-		app.Stop()
-	})
+	populateMenu(app, connectionsList)
 
 	// Update help text
 	helpText = tview.NewTextView().
@@ -681,6 +1299,16 @@ func main() {
 	helpText.SetTextColor(tcell.ColorWhite)
 	helpText.SetBorderColor(tcell.ColorWhite)
 
+	// Undo and redo results above the help text
+	statusText = tview.NewTextView()
+	statusText.SetBackgroundColor(tcell.ColorNavy)
+
+	// Agent status next to the help text
+	agentText = tview.NewTextView().SetDynamicColors(true)
+	agentText.SetBackgroundColor(tcell.ColorNavy)
+	agentText.SetTextColor(tcell.ColorWhite)
+	refreshAgentStatus(app, 0)
+
 	// Set scrolling properties for connections list
 	connectionsList.SetChangedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
 		// Auto-scroll when reaching visible area boundaries
@@ -694,6 +1322,7 @@ func main() {
 		} else if index >= offset+visibleItems {
 			connectionsList.SetOffset(index-visibleItems+1, 0)
 		}
+		refreshAgentStatus(app, index)
 	})
 
 	// Add focus change handlers to manage selection colors
@@ -728,6 +1357,8 @@ func main() {
 
 	// Update key handler in main()
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		lastActivity = time.Now()
+
 		// Get current active primitive
 		primitive := app.GetFocus()
 
@@ -741,6 +1372,10 @@ func main() {
 		if _, ok := primitive.(*tview.Form); ok {
 			return event
 		}
+		// Dropdowns and other secondary widgets handle their own keys
+		if primitive != connectionsList && primitive != menuList && event.Key() != tcell.KeyCtrlC {
+			return event
+		}
 
 		// Only handle Tab for main lists, not for forms
 		if event.Key() == tcell.KeyTab {
@@ -759,6 +1394,7 @@ func main() {
 			currentIndex := connectionsList.GetCurrentItem()
 			refreshConnectionsList(app, connectionsList, currentIndex)
 			checkHostsOnline(app, connectionsList, sshConnections)
+			reloadAgentStatus(app, currentIndex)
 			app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
 			// Restore focus to the previously focused element
 			if currentFocus == connectionsList {
@@ -777,7 +1413,17 @@ func main() {
 				app.SetFocus(connectionsList)
 			}
 			return nil
+		case tcell.KeyCtrlZ:
+			undoOperation(app, connectionsList)
+			return nil
+		case tcell.KeyCtrlY:
+			redoOperation(app, connectionsList)
+			return nil
 		case tcell.KeyDown:
+			if app.GetFocus() == connectionsList && event.Modifiers()&tcell.ModShift != 0 {
+				moveConnection(app, connectionsList, connectionsList.GetCurrentItem(), 1)
+				return nil
+			}
 			if app.GetFocus() == connectionsList {
 				// Wrap around at the end
 				if connectionsList.GetCurrentItem() == connectionsList.GetItemCount()-1 {
@@ -792,6 +1438,10 @@ func main() {
 				}
 			}
 		case tcell.KeyUp:
+			if app.GetFocus() == connectionsList && event.Modifiers()&tcell.ModShift != 0 {
+				moveConnection(app, connectionsList, connectionsList.GetCurrentItem(), -1)
+				return nil
+			}
 			if app.GetFocus() == connectionsList {
 				// Wrap around at the beginning
 				if connectionsList.GetCurrentItem() == 0 {
@@ -851,6 +1501,39 @@ func main() {
 				})
 			app.SetRoot(centerWidget(app, modal), true)
 			return nil
+		case tcell.KeyRune:
+			if app.GetFocus() != connectionsList || len(sshConnections) == 0 {
+				return event
+			}
+			currentIndex := connectionsList.GetCurrentItem()
+			switch event.Rune() {
+			case ' ':
+				// Toggle selection and move to the next connection
+				server := sshConnections[currentIndex].Server
+				if selected[server] {
+					delete(selected, server)
+				} else {
+					selected[server] = true
+				}
+				if currentIndex < len(sshConnections)-1 {
+					currentIndex++
+				}
+			case '+':
+				for _, conn := range sshConnections {
+					selected[conn.Server] = true
+				}
+			case '-':
+				selected = make(map[string]bool)
+			default:
+				return event
+			}
+			refreshConnectionsList(app, connectionsList, currentIndex)
+			return nil
+		case tcell.KeyCtrlO:
+			if app.GetFocus() == connectionsList && len(sshConnections) > 0 {
+				showConnectionActions(app, connectionsList, connectionsList.GetCurrentItem())
+			}
+			return nil
 		case tcell.KeyDelete:
 			if app.GetFocus() == connectionsList && connectionsList.GetItemCount() > 0 {
 				deleteConnection(app, connectionsList, connectionsList.GetCurrentItem())
@@ -862,8 +1545,36 @@ func main() {
 
 	checkHostsOnline(app, connectionsList, sshConnections)
 
+	app.SetRoot(flex, true)
+	if configEncrypted {
+		if configLocked {
+			showUnlock(app, connectionsList)
+		}
+		lastActivity = time.Now()
+		go watchConfigLock(app, connectionsList)
+	}
+
+	screen, err := tcell.NewScreen()
+	if err == nil {
+		err = screen.Init()
+	}
+	if err != nil {
+		log.Fatalf(currentLang["msg_app_error"], err)
+	}
+	ui := &uiScreen{Screen: screen}
+	app.SetScreen(ui)
+
+	// Messages logged by background goroutines go to the status line instead of over the screen
+	log.SetOutput(statusWriter{app, ui})
+
 	// Launch application with flex container
-	if err := app.SetRoot(flex, true).EnableMouse(true).Run(); err != nil {
+	err = app.EnableMouse(true).Run()
+	log.SetOutput(os.Stderr)
+	stopAllTunnels()
+	if !configLocked {
+		closeJournal()
+	}
+	if err != nil {
 		log.Fatalf(currentLang["msg_app_error"], err)
 	}
 }
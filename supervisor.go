@@ -0,0 +1,404 @@
+/*
+* Persistent tunnel supervisor
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Reconnect backoff of persistent tunnels
+const (
+	tunnelMinBackoff  = time.Second
+	tunnelMaxBackoff  = time.Minute
+	tunnelStableTime  = time.Minute // a tunnel up this long resets the backoff
+	tunnelKeepAlive   = 15 * time.Second
+	tunnelAliveProbes = "3"
+	tunnelStartWait   = 5 * time.Second // for the supervisor to take its lock
+)
+
+// Persistent tunnel statuses
+const (
+	tunnelStarting     = "starting"
+	tunnelRunning      = "running"
+	tunnelReconnecting = "reconnecting"
+	tunnelPortBusy     = "port busy"
+)
+
+var (
+	tunnelsDir       = filepath.Join(configDir, "tunnels")
+	unsafeFileChars  = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+	errTunnelStopped = errors.New("tunnel stopped")
+)
+
+// TunnelState is the status file a supervisor keeps for its tunnel
+type TunnelState struct {
+	Server    string    `json:"server"`
+	PID       int       `json:"pid"`                 // supervisor process
+	ChildPID  int       `json:"child_pid,omitempty"` // ssh process, 0 for the built-in client
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	Forwards  []string  `json:"forwards"`
+	Started   time.Time `json:"started"`
+	Updated   time.Time `json:"updated"`
+}
+
+// tunnelPath returns the path of a tunnel file of the server with the given extension
+func tunnelPath(server, extension string) string {
+	return filepath.Join(tunnelsDir, unsafeFileChars.ReplaceAllString(privateName(server), "_")+extension)
+}
+
+// writeTunnelState atomically replaces the state file of the tunnel
+func writeTunnelState(state TunnelState) error {
+	state.Updated = time.Now()
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tunnelsDir, 0755); err != nil {
+		return err
+	}
+	path := tunnelPath(state.Server, ".json")
+	if err := os.WriteFile(path+".tmp", sealLine(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// openTunnelLock opens the lock file of the server tunnel
+// A supervisor holds the lock while it runs; the system releases it when the process
+// ends in any way, so the lock tells whether the tunnel is running
+func openTunnelLock(server string) (*os.File, error) {
+	if err := os.MkdirAll(tunnelsDir, 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(tunnelPath(server, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+}
+
+// tunnelLocked reports whether a supervisor holds the lock of the server tunnel
+func tunnelLocked(server string) bool {
+	lock, err := openTunnelLock(server)
+	if err != nil {
+		return false
+	}
+	defer lock.Close()
+	if tryLockFile(lock) != nil {
+		return true
+	}
+	_ = unlockFile(lock)
+	return false
+}
+
+// loadTunnelStates returns the states of running persistent tunnels
+// State files left behind by killed supervisors are removed
+func loadTunnelStates() ([]TunnelState, error) {
+	paths, err := filepath.Glob(filepath.Join(tunnelsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var states []TunnelState
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			data, err = openLine(data)
+		}
+		if err != nil {
+			continue
+		}
+		var state TunnelState
+		if json.Unmarshal(data, &state) != nil {
+			continue
+		}
+		if !tunnelLocked(state.Server) {
+			_ = os.Remove(path)
+			continue
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// findTunnelState returns the state of the running persistent tunnel of the server
+func findTunnelState(server string) (TunnelState, bool) {
+	states, _ := loadTunnelStates()
+	for _, state := range states {
+		if state.Server == server {
+			return state, true
+		}
+	}
+	return TunnelState{}, false
+}
+
+// checkForwardPorts verifies that the local ports of the forwards can be bound
+func checkForwardPorts(forwards []PortForward) error {
+	for _, forward := range forwards {
+		if forward.Type == forwardRemote {
+			continue
+		}
+		address, err := listenAddress(forward.Listen)
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf(currentLang["msg_port_busy"], address)
+		}
+		listener.Close()
+	}
+	return nil
+}
+
+// startPersistentTunnel launches a detached supervisor process for the forwards of the connection
+// and waits until it holds the tunnel lock, so concurrent starts see the running tunnel
+func startPersistentTunnel(conn SSHConnection) error {
+	if len(conn.Forwards) == 0 {
+		return fmt.Errorf(currentLang["msg_no_forwards_for"], conn.Server)
+	}
+	if err := os.MkdirAll(tunnelsDir, 0755); err != nil {
+		return err
+	}
+	guard, err := os.OpenFile(tunnelPath(conn.Server, ".start"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer guard.Close()
+	if err := lockFile(guard); err != nil {
+		return err
+	}
+	defer unlockFile(guard)
+
+	if tunnelLocked(conn.Server) {
+		return fmt.Errorf(currentLang["msg_tunnel_exists"], conn.Server)
+	}
+	if err := checkForwardPorts(conn.Forwards); err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(tunnelPath(conn.Server, ".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, "tunnels", "run", conn.Server)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	if err := startWithPassphrase(cmd); err != nil {
+		return err
+	}
+	if err := cmd.Process.Release(); err != nil {
+		return err
+	}
+
+	for deadline := time.Now().Add(tunnelStartWait); time.Now().Before(deadline); {
+		if tunnelLocked(conn.Server) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf(currentLang["msg_tunnel_not_started"], conn.Server, logFile.Name())
+}
+
+// stopPersistentTunnel asks the supervisor of the server tunnel to stop
+func stopPersistentTunnel(server string) error {
+	state, ok := findTunnelState(server)
+	if !ok {
+		return fmt.Errorf(currentLang["msg_tunnel_not_found"], server)
+	}
+	return stopProcess(state.PID)
+}
+
+// forwardStrings returns the forwards in ssh command line form
+func forwardStrings(forwards []PortForward) []string {
+	result := make([]string, len(forwards))
+	for i, forward := range forwards {
+		result[i] = forward.String()
+	}
+	return result
+}
+
+// superviseTunnel keeps the forwards of the server open until a stop signal arrives,
+// reconnecting with exponential backoff whenever the tunnel exits
+func superviseTunnel(server string) error {
+	// The output goes to the log file of the tunnel, sealed while the config is encrypted
+	output := &sealedWriter{writer: os.Stderr}
+	defer output.Close()
+	log.SetOutput(output)
+
+	conn, ok := findConnection(server)
+	if !ok {
+		return fmt.Errorf(currentLang["msg_conn_not_found"], server)
+	}
+
+	lock, err := openTunnelLock(server)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := tryLockFile(lock); err != nil {
+		return fmt.Errorf(currentLang["msg_tunnel_exists"], server)
+	}
+
+	stop := make(chan os.Signal, 1)
+	notifyStop(stop)
+	defer os.Remove(tunnelPath(server, ".json"))
+
+	state := TunnelState{
+		Server:   server,
+		PID:      os.Getpid(),
+		Status:   tunnelStarting,
+		Forwards: forwardStrings(conn.Forwards),
+		Started:  time.Now(),
+	}
+	if err := writeTunnelState(state); err != nil {
+		return err
+	}
+
+	backoff := tunnelMinBackoff
+	for {
+		err := checkForwardPorts(conn.Forwards)
+		if err != nil {
+			state.Status = tunnelPortBusy
+		} else {
+			started := time.Now()
+			err = runTunnel(conn, &state, stop)
+			if errors.Is(err, errTunnelStopped) {
+				return nil
+			}
+			recordHistory(server, historyTunnel, strings.Join(state.Forwards, " "), started, err)
+			if time.Since(started) > tunnelStableTime {
+				backoff = tunnelMinBackoff
+			}
+			state.Status = tunnelReconnecting
+		}
+
+		state.ChildPID = 0
+		state.Attempts++
+		state.LastError = ""
+		if err != nil {
+			state.LastError = err.Error()
+		}
+		log.Printf(currentLang["msg_tunnel_retry"], server, state.LastError, backoff)
+		if err := writeTunnelState(state); err != nil {
+			log.Printf(currentLang["msg_write_error"], err)
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > tunnelMaxBackoff {
+			backoff = tunnelMaxBackoff
+		}
+	}
+}
+
+// runTunnel opens the tunnel once and waits until it exits or a stop signal arrives
+func runTunnel(conn SSHConnection, state *TunnelState, stop chan os.Signal) error {
+	if conn.Client == clientNative {
+		return runNativeTunnel(conn, state, stop)
+	}
+
+	args := []string{"-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "BatchMode=yes",
+		"-o", fmt.Sprintf("ServerAliveInterval=%d", int(tunnelKeepAlive.Seconds())),
+		"-o", "ServerAliveCountMax=" + tunnelAliveProbes,
+	}
+	args = append(args, forwardArgs(conn.Forwards)...)
+	args = append(args, sshArgs(conn)...)
+
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	state.Status = tunnelRunning
+	state.ChildPID = cmd.Process.Pid
+	if err := writeTunnelState(*state); err != nil {
+		log.Printf(currentLang["msg_write_error"], err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case <-stop:
+		_ = cmd.Process.Kill()
+		<-done
+		return errTunnelStopped
+	case err := <-done:
+		return err
+	}
+}
+
+// runNativeTunnel opens the forwards over the built-in client and detects dropped
+// connections with keepalive requests
+func runNativeTunnel(conn SSHConnection, state *TunnelState, stop chan os.Signal) error {
+	client, err := dialNative(conn, false)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stopForwards, err := startNativeForwards(client, conn.Forwards)
+	if err != nil {
+		return err
+	}
+	defer stopForwards()
+
+	state.Status = tunnelRunning
+	if err := writeTunnelState(*state); err != nil {
+		log.Printf(currentLang["msg_write_error"], err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Wait()
+	}()
+	ticker := time.NewTicker(tunnelKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return errTunnelStopped
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			reply := make(chan error, 1)
+			go func() {
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}()
+			select {
+			case err := <-reply:
+				if err != nil {
+					return err
+				}
+			case <-time.After(tunnelKeepAlive):
+				return errors.New(currentLang["msg_keepalive_timeout"])
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// detachProcess starts the command in its own session so it outlives the terminal
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// stopProcess asks the process to terminate
+func stopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// inheritFile passes the open file to the process started by the command and returns its
+// descriptor number there
+func inheritFile(cmd *exec.Cmd, file *os.File) (int, error) {
+	cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+	// Descriptors after stdin, stdout and stderr
+	return 2 + len(cmd.ExtraFiles), nil
+}
+
+// notifyStop delivers interrupt and terminate signals to the channel
+func notifyStop(signals chan os.Signal) {
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+}
+
+// shellExec returns a command running the command line with the POSIX shell
+func shellExec(command string) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
+
+// lockFile takes an exclusive lock on the open file, waiting for other processes
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+// tryLockFile takes an exclusive lock on the open file, failing if another process holds it
+func tryLockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachProcess starts the command in a new process group so it outlives the console
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// stopProcess terminates the process; Windows has no graceful termination signal
+func stopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// inheritFile fails: processes cannot inherit extra files on Windows
+func inheritFile(cmd *exec.Cmd, file *os.File) (int, error) {
+	return 0, errors.New(currentLang["msg_inherit_unsupported"])
+}
+
+// notifyStop delivers interrupt signals to the channel
+func notifyStop(signals chan os.Signal) {
+	signal.Notify(signals, os.Interrupt)
+}
+
+// shellExec returns a command running the command line with cmd.exe
+func shellExec(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// lockFile takes an exclusive lock on the open file, waiting for other processes
+func lockFile(file *os.File) error {
+	var overlapped windows.Overlapped
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &overlapped)
+}
+
+// tryLockFile takes an exclusive lock on the open file, failing if another process holds it
+func tryLockFile(file *os.File) error {
+	var overlapped windows.Overlapped
+	return windows.LockFileEx(windows.Handle(file.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &overlapped)
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) error {
+	var overlapped windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &overlapped)
+}
@@ -0,0 +1,108 @@
+/*
+* Connection templates and group defaults
+ */
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// ConnectionDefaults are connection settings a template or a group provides
+type ConnectionDefaults struct {
+	Port         string `json:"port,omitempty"`
+	Username     string `json:"username,omitempty"`
+	IdentityFile string `json:"identity_file,omitempty"`
+	JumpHost     string `json:"jump_host,omitempty"`
+}
+
+// Template is a named set of settings to start new connections from
+type Template struct {
+	Name  string `json:"name"`
+	Group string `json:"group,omitempty"`
+	ConnectionDefaults
+}
+
+// defaultFields pairs the form labels of the default settings with their values
+func (defaults ConnectionDefaults) defaultFields() [][2]string {
+	return [][2]string{
+		{"form_port", defaults.Port},
+		{"form_username", defaults.Username},
+		{"form_identity", defaults.IdentityFile},
+		{"form_jump_host", defaults.JumpHost},
+	}
+}
+
+// resolveConnection returns the connection with empty settings taken from its group
+func resolveConnection(conn SSHConnection) SSHConnection {
+	group, ok := findGroup(conn.Group)
+	if !ok {
+		return conn
+	}
+	if conn.Port == "" {
+		conn.Port = group.Port
+	}
+	if conn.Username == "" {
+		conn.Username = group.Username
+	}
+	if conn.IdentityFile == "" {
+		conn.IdentityFile = group.IdentityFile
+	}
+	if conn.JumpHost == "" {
+		conn.JumpHost = group.JumpHost
+	}
+	return conn
+}
+
+// resolveConnections applies group defaults to each connection
+func resolveConnections(connections []SSHConnection) []SSHConnection {
+	resolved := make([]SSHConnection, len(connections))
+	for i, conn := range connections {
+		resolved[i] = resolveConnection(conn)
+	}
+	return resolved
+}
+
+// setPlaceholder shows a greyed-out hint in an empty input field of a styled form
+func setPlaceholder(input *tview.InputField, text string) {
+	input.SetPlaceholder(text).
+		SetPlaceholderStyle(tcell.StyleDefault.Background(tcell.ColorDarkBlue).Foreground(tcell.ColorGray))
+}
+
+// showGroupDefaults shows the defaults of the group as placeholders of the empty fields
+// of a connection form, following changes of the group field
+func showGroupDefaults(form *tview.Form) {
+	groupField := form.GetFormItemByLabel(currentLang["form_group"]).(*tview.InputField)
+	update := func(name string) {
+		group, _ := findGroup(name)
+		for _, field := range group.defaultFields() {
+			setPlaceholder(form.GetFormItemByLabel(currentLang[field[0]]).(*tview.InputField), field[1])
+		}
+	}
+	groupField.SetChangedFunc(update)
+	update(groupField.GetText())
+}
+
+// addTemplateField adds a dropdown to a connection form that fills the fields from one of
+// the configured templates; nothing is added without templates
+func addTemplateField(form *tview.Form) {
+	if len(config.Templates) == 0 {
+		return
+	}
+	names := []string{currentLang["template_none"]}
+	for _, template := range config.Templates {
+		names = append(names, template.Name)
+	}
+	form.AddDropDown(currentLang["form_template"], names, 0, func(option string, optionIndex int) {
+		if optionIndex == 0 {
+			return
+		}
+		template := config.Templates[optionIndex-1]
+		fields := append(template.defaultFields(), [2]string{"form_group", template.Group})
+		for _, field := range fields {
+			if field[1] != "" {
+				form.GetFormItemByLabel(currentLang[field[0]]).(*tview.InputField).SetText(field[1])
+			}
+		}
+	})
+}
@@ -0,0 +1,71 @@
+/*
+* External terminal launcher
+ */
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Placeholders of the terminal launcher template
+const (
+	terminalCommand = "{ssh}"   // the session command
+	terminalTitle   = "{title}" // the connection server, for window titles
+)
+
+// terminalTemplate returns the launcher template of the connection, falling back to the global one
+func terminalTemplate(conn SSHConnection) string {
+	if conn.Terminal != "" {
+		return conn.Terminal
+	}
+	return config.Terminal
+}
+
+// terminalArgs expands the launcher template for the session command
+// A standalone {ssh} is replaced with the command arguments, {ssh} inside a word with the
+// shell-quoted command line; without {ssh} the command is appended to the template
+func terminalArgs(template string, conn SSHConnection, command []string) []string {
+	var args []string
+	substituted := false
+	for _, field := range strings.Fields(template) {
+		field = strings.ReplaceAll(field, terminalTitle, conn.Server)
+		switch {
+		case field == terminalCommand:
+			args = append(args, command...)
+			substituted = true
+		case strings.Contains(field, terminalCommand):
+			args = append(args, strings.ReplaceAll(field, terminalCommand, shellCommand(command)))
+			substituted = true
+		default:
+			args = append(args, field)
+		}
+	}
+	if !substituted {
+		args = append(args, command...)
+	}
+	return args
+}
+
+// openInTerminal opens the connection in a new window of the configured terminal emulator
+// The terminal runs detached so sshman stays usable while the session is open; with an
+// encrypted config the session asks for the passphrase in its window
+func openInTerminal(conn SSHConnection) error {
+	template := terminalTemplate(conn)
+	if strings.TrimSpace(template) == "" {
+		return errors.New(currentLang["msg_no_terminal"])
+	}
+	command, err := sessionCommand(conn)
+	if err != nil {
+		return err
+	}
+
+	args := terminalArgs(template, conn, command)
+	cmd := exec.Command(args[0], args[1:]...)
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,156 @@
+/*
+* scp/rsync file transfers
+ */
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Transfer tools
+const (
+	transferSCP   = "scp"
+	transferRsync = "rsync"
+)
+
+// transferCommand builds the scp or rsync command copying between the local path
+// and the remote path of the connection
+func transferCommand(conn SSHConnection, tool string, upload, recursive bool, localPath, remotePath string) []string {
+	host := sshTarget(conn)
+	if strings.Contains(conn.Server, ":") && !strings.HasPrefix(conn.Server, "[") {
+		// IPv6 addresses must be bracketed so the path separator is unambiguous
+		host = strings.Replace(host, conn.Server, "["+conn.Server+"]", 1)
+	}
+	remote := host + ":" + remotePath
+
+	source, destination := remote, localPath
+	if upload {
+		source, destination = localPath, remote
+	}
+
+	if tool == transferRsync {
+		shell := []string{"ssh"}
+		if conn.Port != "" {
+			shell = append(shell, "-p", conn.Port)
+		}
+		shell = append(shell, sshOptions(conn)...)
+		for i, arg := range shell {
+			shell[i] = shellQuote(arg)
+		}
+
+		args := []string{"rsync", "-av", "--progress", "-e", strings.Join(shell, " ")}
+		if !recursive {
+			args = append(args, "--no-recursive")
+		}
+		return append(args, source, destination)
+	}
+
+	args := []string{"scp"}
+	if conn.Port != "" {
+		args = append(args, "-P", conn.Port)
+	}
+	args = append(args, sshOptions(conn)...)
+	if recursive {
+		args = append(args, "-r")
+	}
+	return append(args, source, destination)
+}
+
+// shellQuote quotes the argument for a POSIX shell when it contains special characters
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// runTransfer runs the transfer command in the terminal and waits for Enter before returning
+func runTransfer(conn SSHConnection, args []string) {
+	commandLine := strings.Join(args, " ")
+	fmt.Println(commandLine)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = credentialEnv(conn)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	started := time.Now()
+	err := cmd.Run()
+	if err != nil {
+		log.Printf(currentLang["msg_copy_error"], conn.Server, err)
+	} else {
+		fmt.Println(currentLang["msg_copy_done"])
+	}
+	recordHistory(conn.Server, historyCopy, commandLine, started, err)
+
+	fmt.Print(currentLang["prompt_continue"])
+	_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+// copyFiles displays a form for copying files to or from the connection with scp or rsync
+func copyFiles(app *tview.Application, connectionsList *tview.List, conn SSHConnection) {
+	errorText := tview.NewTextView().SetText("")
+	errorText.SetTextColor(tcell.ColorYellow).SetBackgroundColor(tcell.ColorNavy)
+	backToMain := func() {
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	}
+
+	tools := []string{transferSCP, transferRsync}
+	directions := []string{currentLang["copy_upload"], currentLang["copy_download"]}
+
+	form := newStyledForm()
+	form.
+		AddDropDown(currentLang["form_copy_tool"], tools, 0, nil).
+		AddDropDown(currentLang["form_copy_direction"], directions, 0, nil).
+		AddInputField(currentLang["form_copy_local"], "", 40, nil, nil).
+		AddInputField(currentLang["form_copy_remote"], "", 40, nil, nil).
+		AddCheckbox(currentLang["form_copy_recursive"], false, nil).
+		AddButton(currentLang["btn_copy"], func() {
+			toolIndex, _ := form.GetFormItem(0).(*tview.DropDown).GetCurrentOption()
+			direction, _ := form.GetFormItem(1).(*tview.DropDown).GetCurrentOption()
+			localPath := strings.TrimSpace(form.GetFormItem(2).(*tview.InputField).GetText())
+			remotePath := strings.TrimSpace(form.GetFormItem(3).(*tview.InputField).GetText())
+			recursive := form.GetFormItem(4).(*tview.Checkbox).IsChecked()
+
+			if localPath == "" || remotePath == "" {
+				errorText.SetText(currentLang["msg_enter_paths"])
+				return
+			}
+			if _, err := exec.LookPath(tools[toolIndex]); err != nil {
+				errorText.SetText(fmt.Sprintf(currentLang["msg_not_found"], tools[toolIndex]))
+				return
+			}
+
+			args := transferCommand(conn, tools[toolIndex], direction == 0, recursive, expandHome(localPath), remotePath)
+			app.Suspend(func() {
+				runTransfer(conn, args)
+			})
+			backToMain()
+		}).
+		AddButton(currentLang["btn_cancel"], backToMain)
+
+	formFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(errorText, 1, 0, false)
+
+	formFlex.SetBorder(true).
+		SetTitle(fmt.Sprintf(currentLang["title_copy"], conn.Server)).
+		SetTitleAlign(tview.AlignLeft).
+		SetBackgroundColor(tcell.ColorNavy).
+		SetBorderColor(tcell.ColorWhite).
+		SetTitleColor(tcell.ColorWhite)
+
+	app.SetRoot(centerForm(app, formFlex, form), true)
+	app.SetFocus(form)
+}
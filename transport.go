@@ -0,0 +1,112 @@
+/*
+* Session transports: ssh, mosh, Eternal Terminal and custom commands
+ */
+package main
+
+import (
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// Session transports
+const (
+	transportSSH    = "ssh"
+	transportMosh   = "mosh"
+	transportET     = "et"
+	transportCustom = "custom"
+)
+
+// connectionTransports returns the transport values and their display labels in dropdown order
+func connectionTransports() ([]string, []string) {
+	return []string{transportSSH, transportMosh, transportET, transportCustom},
+		[]string{"ssh", "mosh", "et", currentLang["transport_custom"]}
+}
+
+// sessionTransport returns the transport to open the session with
+// Falls back to ssh when the program of the chosen transport is not in PATH
+func sessionTransport(conn SSHConnection) string {
+	program := conn.Transport
+	switch conn.Transport {
+	case "", transportSSH:
+		return transportSSH
+	case transportCustom:
+		program = ""
+		if fields := strings.Fields(conn.TransportOptions); len(fields) > 0 {
+			program = fields[0]
+		}
+	}
+
+	if program == "" {
+		log.Printf(currentLang["msg_transport_fallback"], conn.Transport)
+		return transportSSH
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		log.Printf(currentLang["msg_transport_fallback"], program)
+		return transportSSH
+	}
+	return conn.Transport
+}
+
+// transportCommand returns the program and arguments of an interactive session
+// over the transport of the connection
+func transportCommand(conn SSHConnection) []string {
+	options := strings.Fields(conn.TransportOptions)
+	switch conn.Transport {
+	case transportMosh:
+		args := []string{"mosh"}
+		// mosh starts its server over ssh, which needs the port, key and jump host
+		sshCommand := []string{"ssh"}
+		if conn.Port != "" {
+			sshCommand = append(sshCommand, "-p", conn.Port)
+		}
+		sshCommand = append(sshCommand, sshOptions(conn)...)
+		if len(sshCommand) > 1 {
+			args = append(args, "--ssh="+shellCommand(sshCommand))
+		}
+		args = append(args, options...)
+		args = append(args, sshTarget(conn))
+		if conn.RemoteCommand != "" {
+			args = append(args, "--", "sh", "-c", conn.RemoteCommand)
+		}
+		return args
+
+	case transportET:
+		args := []string{"et"}
+		if conn.Port != "" {
+			args = append(args, "--ssh-option", "Port="+conn.Port)
+		}
+		if conn.IdentityFile != "" {
+			args = append(args, "--ssh-option", "IdentityFile="+expandHome(conn.IdentityFile))
+		}
+		if conn.JumpHost != "" {
+			args = append(args, "--ssh-option", "ProxyJump="+conn.JumpHost)
+		}
+		args = append(args, options...)
+		if conn.RemoteCommand != "" {
+			args = append(args, "-c", conn.RemoteCommand)
+		}
+		return append(args, sshTarget(conn))
+
+	case transportCustom:
+		// Placeholders in the template are replaced with the connection settings
+		port := conn.Port
+		if port == "" {
+			port = "22"
+		}
+		replacer := strings.NewReplacer(
+			"{host}", conn.Server,
+			"{port}", port,
+			"{user}", connectionUser(conn),
+			"{target}", sshTarget(conn),
+			"{identity}", expandHome(conn.IdentityFile),
+		)
+		args := make([]string, len(options))
+		for i, option := range options {
+			args[i] = replacer.Replace(option)
+		}
+		return args
+	}
+
+	return append([]string{"ssh"}, sessionArgs(conn)...)
+}
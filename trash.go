@@ -0,0 +1,163 @@
+/*
+* Trash of deleted connections with restore, purge and expiry
+ */
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// defaultTrashDays is how long deleted connections stay in the trash
+const defaultTrashDays = 30
+
+// TrashedConnection is a deleted connection kept in Config.Trash
+type TrashedConnection struct {
+	SSHConnection
+	Deleted time.Time `json:"deleted"`
+}
+
+// trashDays returns the number of days after which trashed connections are purged,
+// zero to keep them until purged by hand
+func trashDays() int {
+	switch {
+	case config.TrashDays < 0:
+		return 0
+	case config.TrashDays > 0:
+		return config.TrashDays
+	}
+	return defaultTrashDays
+}
+
+// trashConnection moves the connection at the index to the trash
+func trashConnection(index int) {
+	config.Trash = append(config.Trash, TrashedConnection{
+		SSHConnection: sshConnections[index],
+		Deleted:       time.Now(),
+	})
+	sshConnections = append(sshConnections[:index], sshConnections[index+1:]...)
+}
+
+// purgeTrash removes the trashed connection at the index for good, with its stored secret
+// Undo cannot bring the connection back either, see purgeFromJournal
+func purgeTrash(index int) {
+	trashed := config.Trash[index]
+	conn := trashed.SSHConnection
+	released := referencedCredentials()
+	config.Trash = append(config.Trash[:index], config.Trash[index+1:]...)
+	auditChange(auditPurge, &conn, nil)
+	purgeFromJournal(trashed)
+	// The secret stays while a connection or another trashed one with the same server uses it
+	dropUnreferencedCredentials(released)
+}
+
+// purgeExpiredTrash purges connections that have been in the trash longer than trash_days
+func purgeExpiredTrash() {
+	days := trashDays()
+	if days == 0 || configLocked {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	purged := false
+	for i := len(config.Trash) - 1; i >= 0; i-- {
+		if config.Trash[i].Deleted.Before(cutoff) {
+			purgeTrash(i)
+			purged = true
+		}
+	}
+	if purged {
+		saveConnections()
+	}
+}
+
+// restoreTrash moves the trashed connection at the index back to the end of the list
+func restoreTrash(index int) error {
+	conn := config.Trash[index].SSHConnection
+	if isConnectionExists(conn.Server) {
+		return fmt.Errorf(currentLang["msg_restore_exists"], conn.Server)
+	}
+	before := journalSnapshot()
+	config.Trash = append(config.Trash[:index], config.Trash[index+1:]...)
+	sshConnections = append(sshConnections, conn)
+	setHostStatus(conn.Server, false)
+	saveConnections()
+	auditChange(auditRestore, nil, &conn)
+	recordOperation(fmt.Sprintf(currentLang["op_restore"], conn.Server), before)
+	return nil
+}
+
+// showTrash lists the deleted connections: Enter restores, Del purges
+func showTrash(app *tview.Application, connectionsList *tview.List) {
+	backToMain := func() {
+		populateMenu(app, connectionsList)
+		refreshConnectionsList(app, connectionsList, connectionsList.GetCurrentItem())
+		app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetTitle(currentLang["title_trash"]).SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	list.SetBackgroundColor(tcell.ColorNavy)
+	list.SetMainTextColor(tcell.ColorWhite)
+	list.SetSelectedTextColor(tcell.ColorWhite)
+	list.SetSelectedBackgroundColor(tcell.ColorDarkRed)
+
+	hint := tview.NewTextView().SetText(currentLang["trash_help"])
+	hint.SetBackgroundColor(tcell.ColorNavy)
+
+	if len(config.Trash) == 0 {
+		list.AddItem(currentLang["msg_trash_empty"], "", 0, nil)
+	}
+	days := trashDays()
+	for i := len(config.Trash) - 1; i >= 0; i-- {
+		index := i
+		trashed := config.Trash[i]
+		line := fmt.Sprintf(" %s  %-30s %s", trashed.Deleted.Format("2006-01-02 15:04"),
+			tview.Escape(trashed.Server), tview.Escape(trashed.Comment))
+		if days > 0 {
+			left := int(time.Until(trashed.Deleted.AddDate(0, 0, days)).Hours()/24) + 1
+			line += "  [yellow]" + fmt.Sprintf(currentLang["trash_purge_in"], left) + "[-]"
+		}
+		list.AddItem(line, "", 0, func() {
+			if err := restoreTrash(index); err != nil {
+				hint.SetTextColor(tcell.ColorYellow).SetText(" " + err.Error())
+				return
+			}
+			showTrash(app, connectionsList)
+		})
+	}
+
+	list.SetDoneFunc(backToMain)
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyDelete || len(config.Trash) == 0 {
+			return event
+		}
+		// The list shows the newest deletion first
+		index := len(config.Trash) - 1 - list.GetCurrentItem()
+		modal := tview.NewModal()
+		modal.SetBackgroundColor(tcell.ColorNavy)
+		modal.SetTextColor(tcell.ColorWhite)
+		modal.SetButtonBackgroundColor(tcell.ColorDarkRed)
+		modal.SetButtonTextColor(tcell.ColorWhite)
+		modal.
+			SetText(fmt.Sprintf(currentLang["dlg_purge"], config.Trash[index].Server)).
+			AddButtons([]string{currentLang["btn_ok"], currentLang["btn_cancel"]}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				if buttonLabel == currentLang["btn_ok"] {
+					purgeTrash(index)
+					saveConnections()
+				}
+				showTrash(app, connectionsList)
+			})
+		app.SetRoot(centerWidget(app, modal), true)
+		return nil
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(list, 0, 1, true).
+		AddItem(hint, 1, 0, false)
+	app.SetRoot(centerWidget(app, layout), true)
+}
@@ -0,0 +1,263 @@
+/*
+* Background forward-only tunnels
+ */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// tunnel is a background ssh -N process holding the forwards of a connection
+type tunnel struct {
+	server   string
+	forwards []PortForward
+	cmd      *exec.Cmd
+	started  time.Time
+	stderr   bytes.Buffer
+	running  bool
+	err      error
+}
+
+var (
+	tunnelsMutex  sync.Mutex
+	activeTunnels []*tunnel
+	tunnelsTable  *tview.Table  // tunnels panel while it is shown
+	tunnelsDone   chan struct{} // stops the refresh of the shown tunnels panel
+)
+
+// startTunnel starts a forward-only ssh process for the forwards of the connection
+// ssh runs in batch mode because the terminal belongs to the TUI
+func startTunnel(app *tview.Application, connectionsList *tview.List, conn SSHConnection) {
+	if len(conn.Forwards) == 0 {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_no_forwards_for"], conn.Server))
+		return
+	}
+
+	args := []string{"-N", "-o", "ExitOnForwardFailure=yes", "-o", "BatchMode=yes"}
+	args = append(args, forwardArgs(conn.Forwards)...)
+	args = append(args, sshArgs(conn)...)
+
+	if err := checkForwardPorts(conn.Forwards); err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_tunnel_error"], conn.Server, err))
+		return
+	}
+
+	t := &tunnel{server: conn.Server, forwards: conn.Forwards, started: time.Now(), running: true}
+	t.cmd = exec.Command("ssh", args...)
+	t.cmd.Stderr = &t.stderr
+	if err := t.cmd.Start(); err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_tunnel_error"], conn.Server, err))
+		return
+	}
+
+	tunnelsMutex.Lock()
+	activeTunnels = append(activeTunnels, t)
+	tunnelsMutex.Unlock()
+
+	go func() {
+		err := t.cmd.Wait()
+		tunnelsMutex.Lock()
+		t.running = false
+		t.err = err
+		tunnelsMutex.Unlock()
+		recordHistory(t.server, historyTunnel, strings.Join(args, " "), t.started, err)
+
+	}()
+
+	showTunnels(app, connectionsList)
+}
+
+// stopTunnel terminates the tunnel process if it is running and removes it from the panel
+func stopTunnel(t *tunnel) {
+	tunnelsMutex.Lock()
+	defer tunnelsMutex.Unlock()
+
+	if t.running {
+		_ = t.cmd.Process.Kill()
+	}
+	for i, active := range activeTunnels {
+		if active == t {
+			activeTunnels = append(activeTunnels[:i], activeTunnels[i+1:]...)
+			break
+		}
+	}
+}
+
+// stopAllTunnels terminates all running tunnels, called when the application exits
+func stopAllTunnels() {
+	tunnelsMutex.Lock()
+	defer tunnelsMutex.Unlock()
+
+	for _, t := range activeTunnels {
+		if t.running {
+			_ = t.cmd.Process.Kill()
+		}
+	}
+}
+
+// tunnelRow is a row of the tunnels panel: a session tunnel or a persistent tunnel
+type tunnelRow struct {
+	session    *tunnel
+	persistent *TunnelState
+}
+
+// Interval between refreshes of the tunnels panel
+const tunnelsRefresh = 2 * time.Second
+
+// fillTunnelsTable renders session and persistent tunnels with their PIDs and status
+// into the table and returns the tunnel of each row
+func fillTunnelsTable(table *tview.Table) []tunnelRow {
+	tunnelsMutex.Lock()
+	defer tunnelsMutex.Unlock()
+
+	row, _ := table.GetSelection()
+	table.Clear()
+	titles := []string{currentLang["tunnels_type"], "PID", currentLang["tunnels_server"], currentLang["tunnels_forwards"], currentLang["tunnels_status"]}
+	for column, title := range titles {
+		table.SetCell(0, column, tview.NewTableCell(title).SetSelectable(false).SetAttributes(tcell.AttrBold))
+	}
+
+	var rows []tunnelRow
+	addRow := func(kind string, pid int, server string, forwards []string, status string) {
+		rows = append(rows, tunnelRow{})
+		table.SetCell(len(rows), 0, tview.NewTableCell(kind))
+		table.SetCell(len(rows), 1, tview.NewTableCell(fmt.Sprint(pid)))
+		table.SetCell(len(rows), 2, tview.NewTableCell(tview.Escape(server)))
+		table.SetCell(len(rows), 3, tview.NewTableCell(strings.Join(forwards, ", ")))
+		table.SetCell(len(rows), 4, tview.NewTableCell(status).SetExpansion(1))
+	}
+
+	for _, t := range activeTunnels {
+		status := fmt.Sprintf("[green]%s %s[-]", currentLang["tunnels_running"], time.Since(t.started).Round(time.Second))
+		if !t.running {
+			message := strings.TrimSpace(t.stderr.String())
+			if message == "" && t.err != nil {
+				message = t.err.Error()
+			}
+			status = fmt.Sprintf("[red]%s[-] %s", currentLang["tunnels_exited"], tview.Escape(message))
+		}
+		addRow(currentLang["tunnels_session"], t.cmd.Process.Pid, t.server, forwardStrings(t.forwards), status)
+		rows[len(rows)-1].session = t
+	}
+
+	states, _ := loadTunnelStates()
+	for i := range states {
+		state := &states[i]
+		status := fmt.Sprintf("[green]%s %s[-]", currentLang["tunnels_running"], time.Since(state.Updated).Round(time.Second))
+		if state.Status != tunnelRunning {
+			status = fmt.Sprintf("[yellow]%s[-] #%d %s", state.Status, state.Attempts, tview.Escape(state.LastError))
+		}
+		addRow(currentLang["tunnels_persistent"], state.PID, state.Server, state.Forwards, status)
+		rows[len(rows)-1].persistent = state
+	}
+
+	if row < 1 {
+		row = 1
+	}
+	if row > len(rows) {
+		row = len(rows)
+	}
+	table.Select(row, 0)
+	return rows
+}
+
+// showTunnels displays the tunnels panel; Enter or Del stops the selected tunnel
+func showTunnels(app *tview.Application, connectionsList *tview.List) {
+	table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	table.SetBorder(true).SetTitle(currentLang["title_tunnels"]).SetTitleAlign(tview.AlignLeft)
+	table.SetBackgroundColor(tcell.ColorNavy)
+	table.SetSelectedStyle(tcell.StyleDefault.Background(tcell.ColorDarkRed).Foreground(tcell.ColorWhite))
+	rows := fillTunnelsTable(table)
+	closeTunnelsPanel()
+	tunnelsTable = table
+	tunnelsDone = make(chan struct{})
+
+	// Persistent tunnels change in other processes, so poll while the panel is shown
+	done := tunnelsDone
+	go func() {
+		ticker := time.NewTicker(tunnelsRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				app.QueueUpdateDraw(func() {
+					if tunnelsTable != table {
+						return
+					}
+					// The panel was replaced without Escape, e.g. by the lock screen
+					if app.GetFocus() != table {
+						closeTunnelsPanel()
+						return
+					}
+					rows = fillTunnelsTable(table)
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	hint := tview.NewTextView().SetText(currentLang["tunnels_help"])
+	hint.SetBackgroundColor(tcell.ColorNavy)
+
+	stopSelected := func() {
+		row, _ := table.GetSelection()
+		if row < 1 || row > len(rows) {
+			return
+		}
+		if rows[row-1].session != nil {
+			stopTunnel(rows[row-1].session)
+		} else if err := stopPersistentTunnel(rows[row-1].persistent.Server); err != nil {
+			hint.SetText(fmt.Sprintf(currentLang["msg_tunnel_error"], rows[row-1].persistent.Server, err))
+		}
+		rows = fillTunnelsTable(table)
+	}
+	table.SetSelectedFunc(func(row, column int) {
+		stopSelected()
+	})
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyDelete {
+			stopSelected()
+			return nil
+		}
+		return event
+	})
+	table.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			closeTunnelsPanel()
+			app.SetRoot(centerWidget(app, createMainLayout(app, connectionsList)), true)
+		}
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(hint, 1, 0, false)
+	app.SetRoot(centerWidget(app, layout), true)
+}
+
+// closeTunnelsPanel stops the refresh of the tunnels panel when it is left
+func closeTunnelsPanel() {
+	if tunnelsDone != nil {
+		close(tunnelsDone)
+		tunnelsDone = nil
+	}
+	tunnelsTable = nil
+}
+
+// startPersistentTunnelFromUI launches the supervisor for the connection and shows the tunnels panel
+func startPersistentTunnelFromUI(app *tview.Application, connectionsList *tview.List, conn SSHConnection) {
+	if err := startPersistentTunnel(conn); err != nil {
+		showError(app, connectionsList, fmt.Sprintf(currentLang["msg_tunnel_error"], conn.Server, err))
+		return
+	}
+	showTunnels(app, connectionsList)
+}